@@ -0,0 +1,162 @@
+package cbuildapp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/cache"
+	"gitlab.com/rpnx/cbuild-go/pkg/ccommon"
+	"gitlab.com/rpnx/cbuild-go/pkg/cli"
+)
+
+func runCache(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: cbuild cache <stats|prune> [--max-age <age>] [--max-size <size>]")
+	}
+
+	dryRun := cli.GetBool(ctx, cli.FlagKey(ccommon.FlagDryRun))
+
+	ws, err := loadWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	dir, err := ws.CacheDir()
+	if err != nil {
+		return err
+	}
+	c := cache.New(dir)
+
+	switch args[0] {
+	case "stats":
+		stats, err := c.Stats()
+		if err != nil {
+			return fmt.Errorf("error reading cache: %w", err)
+		}
+
+		hitRate := 0.0
+		if lookups := stats.Hits + stats.Misses; lookups > 0 {
+			hitRate = 100 * float64(stats.Hits) / float64(lookups)
+		}
+
+		enabled := ws.EffectiveCacheConfig().IsEnabled()
+		fmt.Printf("Cache directory: %s\n", dir)
+		fmt.Printf("Enabled:         %t\n", enabled)
+		fmt.Printf("Entries:         %d\n", stats.Entries)
+		fmt.Printf("Total size:      %s\n", cache.FormatSize(stats.TotalSize))
+		fmt.Printf("Hits:            %d\n", stats.Hits)
+		fmt.Printf("Misses:          %d\n", stats.Misses)
+		fmt.Printf("Hit rate:        %.1f%%\n", hitRate)
+		return nil
+
+	case "prune":
+		var maxAge time.Duration
+		var maxSize int64
+
+		if s := cli.GetString(ctx, cli.FlagKey(ccommon.FlagMaxAge)); s != "" {
+			maxAge, err = cache.ParseAge(s)
+			if err != nil {
+				return err
+			}
+		}
+		if s := cli.GetString(ctx, cli.FlagKey(ccommon.FlagMaxSize)); s != "" {
+			maxSize, err = cache.ParseSize(s)
+			if err != nil {
+				return err
+			}
+		}
+		if maxAge == 0 && maxSize == 0 {
+			return fmt.Errorf("cache prune requires --max-age and/or --max-size")
+		}
+
+		if dryRun {
+			fmt.Printf("dry-run: would prune cache %s\n", dir)
+			return nil
+		}
+
+		removed, freed, err := c.Prune(maxAge, maxSize)
+		if err != nil {
+			return fmt.Errorf("error pruning cache: %w", err)
+		}
+		fmt.Printf("Removed %d cache entries, freed %s\n", removed, cache.FormatSize(freed))
+		return nil
+	}
+
+	return fmt.Errorf("unknown cache action %q, expected stats or prune", args[0])
+}
+
+func runCacheServer(ctx context.Context, args []string) error {
+	listen := cli.GetString(ctx, cli.FlagKey(ccommon.FlagListen))
+	if listen == "" {
+		listen = ":8080"
+	}
+	readOnly := cli.GetBool(ctx, cli.FlagKey(ccommon.FlagReadOnly))
+
+	var maxUploadSize int64
+	if s := cli.GetString(ctx, cli.FlagKey(ccommon.FlagMaxUpload)); s != "" {
+		var err error
+		maxUploadSize, err = cache.ParseSize(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	dir := cli.GetString(ctx, cli.FlagKey(ccommon.FlagCacheDir))
+	if dir == "" {
+		workspacePath := cli.GetString(ctx, cli.FlagKey(ccommon.FlagWorkspace))
+		if workspacePath == "" {
+			workspacePath = "."
+		}
+
+		ws := &ccommon.WorkspaceContext{}
+		err := ws.Load(ctx, workspacePath)
+		if err != nil {
+			return fmt.Errorf("error loading configuration (use --cache-dir to serve a directory without a workspace): %w", err)
+		}
+
+		dir, err = ws.CacheDir()
+		if err != nil {
+			return err
+		}
+	}
+
+	mode := ccommon.RemoteCacheReadWrite
+	if readOnly {
+		mode = ccommon.RemoteCacheReadOnly
+	}
+	// Uploads of several gigabytes must fit in the read timeout, slow or
+	// stalled clients are cut off by the header and idle timeouts.
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           cache.Handler(dir, readOnly, maxUploadSize),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Minute,
+		WriteTimeout:      30 * time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServe()
+	}()
+
+	fmt.Printf("Serving cache %s on %s (%s), press Ctrl-C to stop\n", dir, listen, mode)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Println("Shutting down cache server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
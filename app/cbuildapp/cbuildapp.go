@@ -26,7 +26,7 @@ var CBuild = &cli.Runner{
 func init() {
 	CBuild.Subcommands["build"] = &cli.Subcommand{
 		Description:  "Build the project",
-		AcceptsFlags: []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag, ccommon.TargetFlag},
+		AcceptsFlags: []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag, ccommon.VariantFlag, ccommon.TargetFlag, ccommon.ResumeFlag, ccommon.OnlyFailedFlag, ccommon.RdepsFlag, ccommon.DepsOnlyFlag, ccommon.NoDepsFlag},
 		Exec: func(ctx context.Context, args []string) error {
 			return runBuild(ctx, "build", args)
 		},
@@ -34,25 +34,169 @@ func init() {
 
 	CBuild.Subcommands["clean"] = &cli.Subcommand{
 		Description:  "Clean build artifacts",
-		AcceptsFlags: []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag},
+		AcceptsFlags: []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag, ccommon.VariantFlag, ccommon.TargetFlag, ccommon.StagingFlag, ccommon.ExportsFlag, ccommon.AllFlag},
 		Exec: func(ctx context.Context, args []string) error {
 			return runClean(ctx, args)
 		},
 	}
 
+	CBuild.Subcommands["graph"] = &cli.Subcommand{
+		Description:  "Print the target dependency graph as DOT, Mermaid or JSON",
+		AcceptsFlags: []cli.Flag{ccommon.GraphFormatFlag, ccommon.FromFlag, ccommon.ToFlag, ccommon.StatusFlag, ccommon.ConfigFlag, ccommon.ToolchainFlag, ccommon.VariantFlag},
+		Exec: func(ctx context.Context, args []string) error {
+			return runGraph(ctx, args)
+		},
+	}
+
+	CBuild.Subcommands["gc"] = &cli.Subcommand{
+		Description: "Delete build, staging and export directories of removed targets, toolchains and configurations",
+		Exec: func(ctx context.Context, args []string) error {
+			return runGC(ctx, args)
+		},
+	}
+
 	CBuild.Subcommands["build-deps"] = &cli.Subcommand{
 		Description: "Build dependencies for a source",
 		Arguments: []cli.Argument{
 			{Name: "sourcename", Required: true},
 		},
-		AcceptsFlags: []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag, ccommon.TargetFlag},
+		AcceptsFlags: []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag, ccommon.VariantFlag, ccommon.TargetFlag, ccommon.ResumeFlag, ccommon.OnlyFailedFlag},
 		Exec: func(ctx context.Context, args []string) error {
-			if len(args) != 1 {
+			// The source name is usually taken by the target flag's argument.
+			if len(args) > 1 || (len(args) == 0 && cli.GetString(ctx, cli.FlagKey(ccommon.FlagTarget)) == "") {
 				return fmt.Errorf("usage: cbuild build-deps <sourcename>")
 			}
 			return runBuild(ctx, "build-deps", args)
 		},
 	}
+
+	CBuild.Subcommands["watch"] = &cli.Subcommand{
+		Description:  "Build, then rebuild targets and their dependents whenever their sources change",
+		AcceptsFlags: []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag, ccommon.VariantFlag, ccommon.TargetFlag, ccommon.IntervalFlag, ccommon.DebounceFlag},
+		Exec: func(ctx context.Context, args []string) error {
+			return runWatch(ctx, args)
+		},
+	}
+
+	CBuild.Subcommands["cache"] = &cli.Subcommand{
+		Description: "Show statistics for or prune the staging cache",
+		Arguments: []cli.Argument{
+			{Name: "stats|prune", Required: true},
+		},
+		AcceptsFlags: []cli.Flag{ccommon.MaxAgeFlag, ccommon.MaxSizeFlag},
+		Exec: func(ctx context.Context, args []string) error {
+			return runCache(ctx, args)
+		},
+	}
+
+	CBuild.Subcommands["cache-server"] = &cli.Subcommand{
+		Description:  "Serve a cache directory over HTTP for use as a remote cache",
+		AcceptsFlags: []cli.Flag{ccommon.ListenFlag, ccommon.CacheDirFlag, ccommon.ReadOnlyFlag, ccommon.MaxUploadSizeFlag},
+		Exec: func(ctx context.Context, args []string) error {
+			return runCacheServer(ctx, args)
+		},
+	}
+
+	CBuild.Subcommands["compdb"] = &cli.Subcommand{
+		Description:  "Merge the compile_commands.json of every target into the workspace root",
+		AcceptsFlags: []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag, ccommon.VariantFlag, ccommon.TargetFlag, ccommon.LinkFlag},
+		Exec: func(ctx context.Context, args []string) error {
+			return runCompdb(ctx, args)
+		},
+	}
+
+	CBuild.Subcommands["env"] = &cli.Subcommand{
+		Description:  "Print the runtime environment of a target as shell exports",
+		AcceptsFlags: []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag, ccommon.VariantFlag, ccommon.TargetFlag, ccommon.EnvFormatFlag},
+		Exec: func(ctx context.Context, args []string) error {
+			return runEnv(ctx, args)
+		},
+	}
+
+	CBuild.Subcommands["shell"] = &cli.Subcommand{
+		Description:  "Start a subshell with the runtime environment of a target",
+		AcceptsFlags: []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag, ccommon.VariantFlag, ccommon.TargetFlag},
+		Exec: func(ctx context.Context, args []string) error {
+			return runShell(ctx, args)
+		},
+	}
+
+	CBuild.Subcommands["run"] = &cli.Subcommand{
+		Description: "Build a target and run one of its executables, arguments after -- are passed to it",
+		Arguments: []cli.Argument{
+			{Name: "executable", Required: false},
+		},
+		AcceptsFlags:          []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag, ccommon.VariantFlag, ccommon.TargetFlag, ccommon.NoBuildFlag, ccommon.GdbFlag, ccommon.ValgrindFlag, ccommon.WrapFlag},
+		AllowUnknownFlags:     true,
+		AllowUnrecognizedArgs: true,
+		Exec: func(ctx context.Context, args []string) error {
+			return runRun(ctx, args)
+		},
+	}
+}
+
+// loadWorkspace loads the workspace selected by the --workspace flag.
+func loadWorkspace(ctx context.Context) (*ccommon.WorkspaceContext, error) {
+	workspacePath := cli.GetString(ctx, cli.FlagKey(ccommon.FlagWorkspace))
+	if workspacePath == "" {
+		workspacePath = "."
+	}
+
+	ws := &ccommon.WorkspaceContext{}
+	err := ws.Load(ctx, workspacePath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading configuration: %w", err)
+	}
+	return ws, nil
+}
+
+// singleBuildParameters resolves the -T, -c and -V flags for subcommands that
+// operate on exactly one toolchain, configuration and variant. The toolchain
+// may be omitted if the workspace has only one, and the configuration and
+// variant default to the first of the workspace.
+func singleBuildParameters(ctx context.Context, ws *ccommon.WorkspaceContext) (ccommon.TargetBuildParameters, error) {
+	bp := ccommon.TargetBuildParameters{
+		Toolchain: cli.GetString(ctx, cli.FlagKey(ccommon.FlagToolchain)),
+		BuildType: cli.GetString(ctx, cli.FlagKey(ccommon.FlagConfig)),
+		DryRun:    cli.GetBool(ctx, cli.FlagKey(ccommon.FlagDryRun)),
+	}
+
+	if bp.Toolchain == "" {
+		toolchains, err := ws.ListToolchains(ctx)
+		if err != nil {
+			return bp, fmt.Errorf("error listing toolchains: %w", err)
+		}
+		if len(toolchains) != 1 {
+			return bp, fmt.Errorf("workspace has %d toolchains, select one with -T", len(toolchains))
+		}
+		bp.Toolchain = toolchains[0]
+	}
+	if strings.Contains(bp.Toolchain, ",") {
+		return bp, fmt.Errorf("expected a single toolchain, got %q", bp.Toolchain)
+	}
+
+	if bp.BuildType == "" {
+		bp.BuildType = ws.Config.Configurations[0]
+	}
+	if strings.Contains(bp.BuildType, ",") {
+		return bp, fmt.Errorf("expected a single configuration, got %q", bp.BuildType)
+	}
+	err := ccommon.ValidateConfigurationName(bp.BuildType)
+	if err != nil {
+		return bp, err
+	}
+
+	variantFlag := cli.GetString(ctx, cli.FlagKey(ccommon.FlagVariant))
+	if strings.Contains(variantFlag, ",") {
+		return bp, fmt.Errorf("expected a single variant, got %q", variantFlag)
+	}
+	variants, err := ws.ResolveVariants(variantFlag)
+	if err != nil {
+		return bp, err
+	}
+	bp.Variant = variants[0]
+
+	return bp, nil
 }
 
 func runClean(ctx context.Context, args []string) error {
@@ -64,6 +208,11 @@ func runClean(ctx context.Context, args []string) error {
 	}
 
 	dryRun := cli.GetBool(ctx, cli.FlagKey(ccommon.FlagDryRun))
+	all := cli.GetBool(ctx, cli.FlagKey(ccommon.FlagAll))
+	opts := ccommon.CleanOptions{
+		Staging: all || cli.GetBool(ctx, cli.FlagKey(ccommon.FlagStaging)),
+		Exports: all || cli.GetBool(ctx, cli.FlagKey(ccommon.FlagExports)),
+	}
 
 	ws := &ccommon.WorkspaceContext{}
 	err := ws.Load(ctx, workspacePath)
@@ -71,6 +220,11 @@ func runClean(ctx context.Context, args []string) error {
 		return fmt.Errorf("error loading configuration: %w", err)
 	}
 
+	err = ws.LoadBuildState(ctx)
+	if err != nil {
+		return fmt.Errorf("error loading build state: %w", err)
+	}
+
 	toolchainFlag := cli.GetString(ctx, cli.FlagKey(ccommon.FlagToolchain))
 
 	var toolchainNames []string
@@ -83,39 +237,52 @@ func runClean(ctx context.Context, args []string) error {
 		toolchainNames = strings.Split(toolchainFlag, ",")
 	}
 
-	configs := []string{}
-	if buildConfig == "" {
-		configs = ws.Config.Configurations
-	} else {
-		configs = strings.Split(buildConfig, ",")
+	configs, err := ws.ResolveConfigurations(buildConfig)
+	if err != nil {
+		return err
 	}
 
-	var targets []string
-	if len(targetFlag) == 0 {
-		targets = ws.ListTargets(ctx)
-	} else {
-		targets = strings.Split(targetFlag, ",")
+	targets, err := ws.ResolveTargets(ctx, targetFlag)
+	if err != nil {
+		return err
 	}
 
 	//fmt.Printf("Cleaning %d targets: %s\n", len(targets), targets)
 
+	variants, err := ws.ResolveVariants(cli.GetString(ctx, cli.FlagKey(ccommon.FlagVariant)))
+	if err != nil {
+		return err
+	}
+
 	for _, target := range targets {
 		for _, toolchain := range toolchainNames {
 			for _, config := range configs {
-				bp := ccommon.TargetBuildParameters{
-					Toolchain: toolchain,
-					BuildType: config,
-					DryRun:    dryRun,
-				}
-				err = ws.CleanTarget(ctx, target, bp)
-				if err != nil {
-					return fmt.Errorf("error cleaning target %q: %w", target, err)
+				for _, variant := range variants {
+					bp := ccommon.TargetBuildParameters{
+						Toolchain: toolchain,
+						BuildType: config,
+						Variant:   variant,
+						DryRun:    dryRun,
+					}
+					err = ws.CleanTarget(ctx, target, bp, opts)
+					if err != nil {
+						return fmt.Errorf("error cleaning target %q: %w", target, err)
+					}
 				}
 			}
 		}
 
 	}
 
+	if all {
+		for _, toolchain := range toolchainNames {
+			err = ws.CleanToolchain(ctx, ccommon.TargetBuildParameters{Toolchain: toolchain, DryRun: dryRun})
+			if err != nil {
+				return fmt.Errorf("error cleaning toolchain %q: %w", toolchain, err)
+			}
+		}
+	}
+
 	fmt.Println("Clean completed successfully")
 	return nil
 }
@@ -132,9 +299,31 @@ func runBuild(ctx context.Context, command string, args []string) error {
 		toolchain = "all"
 	}
 	dryRun := cli.GetBool(ctx, cli.FlagKey(ccommon.FlagDryRun))
+	resume := cli.GetBool(ctx, cli.FlagKey(ccommon.FlagResume))
+	onlyFailed := cli.GetBool(ctx, cli.FlagKey(ccommon.FlagOnlyFailed))
+
+	if resume && onlyFailed {
+		return fmt.Errorf("--resume and --only-failed cannot be used together")
+	}
+
+	selection := ccommon.BuildSelection{
+		Dependents: cli.GetBool(ctx, cli.FlagKey(ccommon.FlagRdeps)),
+		DepsOnly:   cli.GetBool(ctx, cli.FlagKey(ccommon.FlagDepsOnly)),
+		NoDeps:     cli.GetBool(ctx, cli.FlagKey(ccommon.FlagNoDeps)),
+	}
+	if selection.DepsOnly && selection.NoDeps {
+		return fmt.Errorf("--deps-only and --no-deps cannot be used together")
+	}
 
 	if command == "build-deps" {
-		targetName = args[0]
+		if len(args) == 1 {
+			targetName = args[0]
+		}
+		selection.DepsOnly = true
+	}
+
+	if targetName == "" && (selection.Dependents || selection.DepsOnly || selection.NoDeps) {
+		return fmt.Errorf("--rdeps, --deps-only and --no-deps require a target")
 	}
 
 	ws := &ccommon.WorkspaceContext{}
@@ -143,6 +332,11 @@ func runBuild(ctx context.Context, command string, args []string) error {
 		return fmt.Errorf("error loading configuration: %w", err)
 	}
 
+	err = ws.LoadBuildState(ctx)
+	if err != nil {
+		return fmt.Errorf("error loading build state: %w", err)
+	}
+
 	toolchains := []string{}
 	if toolchain == "all" {
 		toolchainDir := filepath.Join(ws.WorkspacePath, "toolchains")
@@ -163,34 +357,51 @@ func runBuild(ctx context.Context, command string, args []string) error {
 		toolchains = append(toolchains, toolchain)
 	}
 
-	configs := []string{}
-	if buildConfig == "" {
-		configs = ws.Config.Configurations
-	} else {
-		configs = strings.Split(buildConfig, ",")
+	configs, err := ws.ResolveConfigurations(buildConfig)
+	if err != nil {
+		return err
+	}
+
+	var roots []string
+	if targetName != "" {
+		roots, err = ws.ResolveTargets(ctx, targetName)
+		if err != nil {
+			return err
+		}
+	}
+
+	variants, err := ws.ResolveVariants(cli.GetString(ctx, cli.FlagKey(ccommon.FlagVariant)))
+	if err != nil {
+		return err
 	}
 
 	for _, tc := range toolchains {
 		for _, cfg := range configs {
-			cfg = strings.TrimSpace(cfg)
-			fmt.Printf("Building with toolchain: %s, config: %s\n", tc, cfg)
+			for _, variant := range variants {
+				if variant != "" {
+					fmt.Printf("Building with toolchain: %s, config: %s, variant: %s\n", tc, cfg, variant)
+				} else {
+					fmt.Printf("Building with toolchain: %s, config: %s\n", tc, cfg)
+				}
 
-			bp := ccommon.TargetBuildParameters{
-				Toolchain: tc,
-				BuildType: cfg,
-				DryRun:    dryRun,
-			}
+				bp := ccommon.TargetBuildParameters{
+					Toolchain:  tc,
+					BuildType:  cfg,
+					Variant:    variant,
+					DryRun:     dryRun,
+					Resume:     resume,
+					OnlyFailed: onlyFailed,
+				}
 
-			if command == "build-deps" {
-				err = ws.BuildDependencies(ctx, targetName, bp)
-			} else if targetName != "" {
-				err = ws.BuildTarget(ctx, targetName, bp)
-			} else {
-				err = ws.Build(ctx, bp)
-			}
+				if len(roots) != 0 {
+					err = ws.BuildSelected(ctx, roots, selection, bp)
+				} else {
+					err = ws.Build(ctx, bp)
+				}
 
-			if err != nil {
-				return fmt.Errorf("error building workspace for toolchain %s, config %s: %w", tc, cfg, err)
+				if err != nil {
+					return fmt.Errorf("error building workspace for toolchain %s, config %s: %w", tc, bp.ConfigDirName(), err)
+				}
 			}
 		}
 	}
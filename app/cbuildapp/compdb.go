@@ -0,0 +1,41 @@
+package cbuildapp
+
+import (
+	"context"
+	"fmt"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/ccommon"
+	"gitlab.com/rpnx/cbuild-go/pkg/cli"
+)
+
+func runCompdb(ctx context.Context, args []string) error {
+	link := cli.GetBool(ctx, cli.FlagKey(ccommon.FlagLink))
+	targetFlag := cli.GetString(ctx, cli.FlagKey(ccommon.FlagTarget))
+
+	ws, err := loadWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	bp, err := singleBuildParameters(ctx, ws)
+	if err != nil {
+		return err
+	}
+
+	targets, err := ws.ResolveTargets(ctx, targetFlag)
+	if err != nil {
+		return err
+	}
+
+	if !ws.Config.ExportCompileCommands {
+		fmt.Println("Warning: export_compile_commands is not enabled in cbuild_workspace.yml, build trees may not have compile_commands.json")
+	}
+
+	count, err := ws.MergeCompileCommands(ctx, targets, bp, link)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Merged %d compile commands for toolchain %s, config %s\n", count, bp.Toolchain, bp.BuildType)
+	return nil
+}
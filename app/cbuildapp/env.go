@@ -0,0 +1,98 @@
+package cbuildapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/ccommon"
+	"gitlab.com/rpnx/cbuild-go/pkg/cli"
+)
+
+func runtimeEnvironment(ctx context.Context) (ccommon.RuntimeEnvironment, string, error) {
+	targetName := cli.GetString(ctx, cli.FlagKey(ccommon.FlagTarget))
+	if targetName == "" {
+		return nil, "", fmt.Errorf("a target is required")
+	}
+
+	ws, err := loadWorkspace(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	targetName, err = ws.ResolveTarget(ctx, targetName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	bp, err := singleBuildParameters(ctx, ws)
+	if err != nil {
+		return nil, "", err
+	}
+
+	env, err := ws.RuntimeEnvironment(ctx, targetName, bp)
+	if err != nil {
+		return nil, "", err
+	}
+	return env, targetName, nil
+}
+
+func runEnv(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: cbuild env <target> [-T <toolchain>] [-c <config>] [--format bash|fish|json]")
+	}
+
+	env, _, err := runtimeEnvironment(ctx)
+	if err != nil {
+		return err
+	}
+
+	format := cli.GetString(ctx, cli.FlagKey(ccommon.FlagFormat))
+	switch format {
+	case "", "bash", "sh", "zsh":
+		return env.WriteBash(os.Stdout)
+	case "fish":
+		return env.WriteFish(os.Stdout)
+	case "json":
+		values := make(map[string]string)
+		for _, v := range env {
+			values[v.Name] = v.Value(os.Getenv(v.Name))
+		}
+		data, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	default:
+		return fmt.Errorf("unknown format %q, expected bash, fish or json", format)
+	}
+
+	return nil
+}
+
+func runShell(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: cbuild shell <target> [-T <toolchain>] [-c <config>]")
+	}
+
+	env, targetName, err := runtimeEnvironment(ctx)
+	if err != nil {
+		return err
+	}
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+
+	fmt.Printf("Entering %s with the runtime environment of %s, exit to return\n", shell, targetName)
+
+	cmd := exec.CommandContext(ctx, shell)
+	cmd.Env = append(env.Environ(os.Environ()), "CBUILD_SHELL_TARGET="+targetName)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,42 @@
+package cbuildapp
+
+import (
+	"context"
+	"fmt"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/cache"
+	"gitlab.com/rpnx/cbuild-go/pkg/ccommon"
+	"gitlab.com/rpnx/cbuild-go/pkg/cli"
+)
+
+func runGC(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: cbuild gc")
+	}
+
+	ws, err := loadWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = ws.LoadBuildState(ctx)
+	if err != nil {
+		return fmt.Errorf("error loading build state: %w", err)
+	}
+
+	dryRun := cli.GetBool(ctx, cli.FlagKey(ccommon.FlagDryRun))
+	result, err := ws.GarbageCollect(ctx, dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Would reclaim"
+	if !dryRun {
+		verb = "Reclaimed"
+		for _, path := range result.Removed {
+			fmt.Printf("Deleted %s\n", path)
+		}
+	}
+	fmt.Printf("%s %s from %d directories\n", verb, cache.FormatSize(result.Reclaimed), len(result.Removed))
+	return nil
+}
@@ -0,0 +1,63 @@
+package cbuildapp
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/ccommon"
+	"gitlab.com/rpnx/cbuild-go/pkg/cli"
+)
+
+func runGraph(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: cbuild graph [--format dot|mermaid|json] [--from <targets>] [--to <targets>] [--status]")
+	}
+
+	ws, err := loadWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	opts := ccommon.GraphOptions{
+		Status: cli.GetBool(ctx, cli.FlagKey(ccommon.FlagStatus)),
+	}
+	if from := cli.GetString(ctx, cli.FlagKey(ccommon.FlagFrom)); from != "" {
+		opts.From, err = ws.ResolveTargets(ctx, from)
+		if err != nil {
+			return err
+		}
+	}
+	if to := cli.GetString(ctx, cli.FlagKey(ccommon.FlagTo)); to != "" {
+		opts.To, err = ws.ResolveTargets(ctx, to)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.Status {
+		opts.BuildParameters, err = singleBuildParameters(ctx, ws)
+		if err != nil {
+			return err
+		}
+		err = ws.LoadBuildState(ctx)
+		if err != nil {
+			return fmt.Errorf("error loading build state: %w", err)
+		}
+	}
+
+	graph, err := ws.DependencyGraph(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	format := cli.GetString(ctx, cli.FlagKey(ccommon.FlagFormat))
+	switch format {
+	case "", "dot":
+		return graph.WriteDOT(os.Stdout)
+	case "mermaid":
+		return graph.WriteMermaid(os.Stdout)
+	case "json":
+		return graph.WriteJSON(os.Stdout)
+	}
+	return fmt.Errorf("unknown format %q, expected dot, mermaid or json", format)
+}
@@ -0,0 +1,116 @@
+package cbuildapp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/ccommon"
+	"gitlab.com/rpnx/cbuild-go/pkg/cli"
+)
+
+const runUsage = "usage: cbuild run <target> [executable] [--gdb|--valgrind|--wrap <cmd>] [-- args...]"
+
+// runWrapper returns the command prefix selected by --gdb, --valgrind or --wrap.
+func runWrapper(ctx context.Context) ([]string, error) {
+	var wrappers [][]string
+	if cli.GetBool(ctx, cli.FlagKey(ccommon.FlagGdb)) {
+		wrappers = append(wrappers, []string{"gdb", "--args"})
+	}
+	if cli.GetBool(ctx, cli.FlagKey(ccommon.FlagValgrind)) {
+		wrappers = append(wrappers, []string{"valgrind"})
+	}
+	if wrap := cli.GetString(ctx, cli.FlagKey(ccommon.FlagWrap)); wrap != "" {
+		wrappers = append(wrappers, strings.Fields(wrap))
+	}
+
+	if len(wrappers) > 1 {
+		return nil, fmt.Errorf("--gdb, --valgrind and --wrap are mutually exclusive")
+	}
+	if len(wrappers) == 0 {
+		return nil, nil
+	}
+	return wrappers[0], nil
+}
+
+func runRun(ctx context.Context, args []string) error {
+	targetName := cli.GetString(ctx, cli.FlagKey(ccommon.FlagTarget))
+	if targetName == "" || targetName == "--" {
+		return fmt.Errorf("a target is required\n%s", runUsage)
+	}
+
+	// Unknown flags are let through so that the program's arguments after --
+	// are kept intact, anything before it must be the executable name.
+	var exe string
+	var programArgs []string
+	for i, arg := range args {
+		if arg == "--" {
+			programArgs = args[i+1:]
+			break
+		}
+		if strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("unknown flag: %s", arg)
+		}
+		if exe != "" {
+			return fmt.Errorf("unexpected argument %q, pass program arguments after --\n%s", arg, runUsage)
+		}
+		exe = arg
+	}
+
+	wrapper, err := runWrapper(ctx)
+	if err != nil {
+		return err
+	}
+
+	ws, err := loadWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	targetName, err = ws.ResolveTarget(ctx, targetName)
+	if err != nil {
+		return err
+	}
+
+	bp, err := singleBuildParameters(ctx, ws)
+	if err != nil {
+		return err
+	}
+
+	err = ws.CheckTargetSupported(ctx, targetName, bp)
+	if err != nil {
+		return err
+	}
+
+	if !cli.GetBool(ctx, cli.FlagKey(ccommon.FlagNoBuild)) {
+		err = ws.LoadBuildState(ctx)
+		if err != nil {
+			return fmt.Errorf("error loading build state: %w", err)
+		}
+		err = ws.BuildTarget(ctx, targetName, bp)
+		if err != nil {
+			return err
+		}
+	}
+
+	spec, err := ws.PrepareRun(ctx, targetName, exe, programArgs, bp)
+	if err != nil {
+		return err
+	}
+
+	command := append(append(wrapper, spec.Path), spec.Args...)
+	if bp.DryRun {
+		fmt.Printf("dry-run: %s\n", strings.Join(command, " "))
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = spec.Env
+	cmd.Dir = spec.Dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,72 @@
+package cbuildapp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/ccommon"
+	"gitlab.com/rpnx/cbuild-go/pkg/cli"
+)
+
+// durationFlag returns the duration given with a flag, or 0 if it is not set.
+func durationFlag(ctx context.Context, flag ccommon.FlagKey) (time.Duration, error) {
+	s := cli.GetString(ctx, cli.FlagKey(flag))
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid --%s %q, expected a positive duration such as 2s or 300ms", flag, s)
+	}
+	return d, nil
+}
+
+func runWatch(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: cbuild watch [-t <target>] [-T <toolchain>] [-c <config>] [--interval <duration>] [--debounce <duration>]")
+	}
+
+	interval, err := durationFlag(ctx, ccommon.FlagInterval)
+	if err != nil {
+		return err
+	}
+	debounce, err := durationFlag(ctx, ccommon.FlagDebounce)
+	if err != nil {
+		return err
+	}
+
+	ws, err := loadWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	bp, err := singleBuildParameters(ctx, ws)
+	if err != nil {
+		return err
+	}
+
+	err = ws.LoadBuildState(ctx)
+	if err != nil {
+		return fmt.Errorf("error loading build state: %w", err)
+	}
+
+	targetFlag := cli.GetString(ctx, cli.FlagKey(ccommon.FlagTarget))
+	roots, err := ws.ResolveTargets(ctx, targetFlag)
+	if err != nil {
+		return err
+	}
+	watching := "all targets"
+	if targetFlag != "" {
+		watching = strings.Join(roots, ", ") + " and their dependencies"
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	fmt.Printf("Watching %s with toolchain %s, config %s, press Ctrl-C to stop\n", watching, bp.Toolchain, bp.BuildType)
+	return ws.Watch(ctx, roots, bp, ccommon.WatchOptions{Interval: interval, Debounce: debounce})
+}
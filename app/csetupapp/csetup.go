@@ -112,6 +112,37 @@ func init() {
 			return handleAddDependency(ctx, getWorkspacePath(ctx), args)
 		},
 	}
+	CSetup.Subcommands["why"] = &cli.Subcommand{
+		Description: "Print every dependency path from a target to another",
+		Arguments: []cli.Argument{
+			{Name: "target", Required: true},
+			{Name: "dependency", Required: true},
+		},
+		AllowUnrecognizedArgs: true,
+		Exec: func(ctx context.Context, args []string) error {
+			return handleWhy(ctx, getWorkspacePath(ctx), args)
+		},
+	}
+	CSetup.Subcommands["rdeps"] = &cli.Subcommand{
+		Description: "List the targets that depend on a target, directly or transitively",
+		Arguments: []cli.Argument{
+			{Name: "target", Required: true},
+		},
+		AllowUnrecognizedArgs: true,
+		Exec: func(ctx context.Context, args []string) error {
+			return handleRdeps(ctx, getWorkspacePath(ctx), args)
+		},
+	}
+	CSetup.Subcommands["tree"] = &cli.Subcommand{
+		Description: "Print the dependency tree of a target",
+		Arguments: []cli.Argument{
+			{Name: "target", Required: true},
+		},
+		AllowUnrecognizedArgs: true,
+		Exec: func(ctx context.Context, args []string) error {
+			return handleTree(ctx, getWorkspacePath(ctx), args)
+		},
+	}
 	CSetup.Subcommands["remove-dependency"] = &cli.Subcommand{
 		Description: "Remove a dependency from a source",
 		Arguments: []cli.Argument{
@@ -189,6 +220,20 @@ func init() {
 			return handleDisableStaging(ctx, getWorkspacePath(ctx), args)
 		},
 	}
+	CSetup.Subcommands["enable-compile-commands"] = &cli.Subcommand{
+		Description:           "Export compile_commands.json from every target's build tree",
+		AllowUnrecognizedArgs: true,
+		Exec: func(ctx context.Context, args []string) error {
+			return handleSetCompileCommands(ctx, getWorkspacePath(ctx), args, true)
+		},
+	}
+	CSetup.Subcommands["disable-compile-commands"] = &cli.Subcommand{
+		Description:           "Stop exporting compile_commands.json",
+		AllowUnrecognizedArgs: true,
+		Exec: func(ctx context.Context, args []string) error {
+			return handleSetCompileCommands(ctx, getWorkspacePath(ctx), args, false)
+		},
+	}
 	CSetup.Subcommands["list-sources"] = &cli.Subcommand{
 		Description:           "List all sources in the workspace",
 		AllowUnrecognizedArgs: true,
@@ -212,18 +257,45 @@ func init() {
 			{Name: "target", Required: true},
 		},
 		AllowUnrecognizedArgs: true,
-		AcceptsFlags:          []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag},
+		AcceptsFlags:          []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag, ccommon.VariantFlag},
 		Exec: func(ctx context.Context, args []string) error {
 			return handleGetArgs(ctx, getWorkspacePath(ctx), args)
 		},
 	}
+	CSetup.Subcommands["gen-presets"] = &cli.Subcommand{
+		Description: "Generate CMakeUserPresets.json for each source, matching cbuild's build trees",
+		Arguments: []cli.Argument{
+			{Name: "target", Required: false},
+		},
+		AllowUnrecognizedArgs: true,
+		AcceptsFlags:          []cli.Flag{ccommon.ConfigFlag, ccommon.ToolchainFlag, ccommon.VariantFlag, ccommon.ForceFlag},
+		Exec: func(ctx context.Context, args []string) error {
+			return handleGenPresets(ctx, getWorkspacePath(ctx), args)
+		},
+	}
 	CSetup.Subcommands["detect-toolchains"] = &cli.Subcommand{
 		Description:           "Detect system toolchains",
 		AllowUnrecognizedArgs: true,
+		AcceptsFlags:          []cli.Flag{ccommon.SearchDirFlag, ccommon.LauncherFlag},
 		Exec: func(ctx context.Context, args []string) error {
 			return handleDetectToolchains(ctx, getWorkspacePath(ctx), args)
 		},
 	}
+	CSetup.Subcommands["toolchain"] = &cli.Subcommand{
+		Description: "List, show, add, remove, rename or copy toolchains",
+		Arguments: []cli.Argument{
+			{Name: "list|show|add|remove|rename|copy", Required: true},
+		},
+		AllowUnrecognizedArgs: true,
+		AcceptsFlags: []cli.Flag{
+			ccommon.CCFlag, ccommon.CXXFlag, ccommon.CFlagsFlag, ccommon.CXXFlagsFlag, ccommon.LDFlagsFlag,
+			ccommon.LinkerTypeFlag, ccommon.SystemFlag, ccommon.ArchFlag, ccommon.HostFlag,
+			ccommon.ToolchainFileFlag, ccommon.ForceFlag, ccommon.DeleteFlag,
+		},
+		Exec: func(ctx context.Context, args []string) error {
+			return handleToolchain(ctx, getWorkspacePath(ctx), args)
+		},
+	}
 	CSetup.Subcommands["add-config"] = &cli.Subcommand{
 		Description:           "Add a build configuration",
 		AllowUnrecognizedArgs: true,
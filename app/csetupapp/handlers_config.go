@@ -58,6 +58,23 @@ func handleDisableStaging(ctx context.Context, workspacePath string, args []stri
 	return ws.SetStaging(ctx, source, false)
 }
 
+func handleSetCompileCommands(ctx context.Context, workspacePath string, args []string, enabled bool) error {
+	if len(args) != 0 {
+		if enabled {
+			return fmt.Errorf("usage: csetup enable-compile-commands")
+		}
+		return fmt.Errorf("usage: csetup disable-compile-commands")
+	}
+
+	ws := &ccommon.WorkspaceContext{}
+	err := ws.Load(ctx, workspacePath)
+	if err != nil {
+		return fmt.Errorf("error loading workspace: %w", err)
+	}
+
+	return ws.SetExportCompileCommands(ctx, enabled)
+}
+
 func handleAddConfig(ctx context.Context, workspacePath string, args []string) error {
 	if len(args) != 1 {
 		return fmt.Errorf("usage: csetup add-config <configname>")
@@ -3,12 +3,15 @@ package csetupapp
 import (
 	"context"
 	"fmt"
+	"strings"
+
 	"gitlab.com/rpnx/cbuild-go/pkg/ccommon"
+	"gitlab.com/rpnx/cbuild-go/pkg/cli"
 )
 
 func handleDetectToolchains(ctx context.Context, workspacePath string, args []string) error {
 	if len(args) != 0 {
-		return fmt.Errorf("usage: csetup detect-toolchains")
+		return fmt.Errorf("usage: csetup detect-toolchains [--search-dir <dirs>] [--launcher]")
 	}
 	ws := &ccommon.WorkspaceContext{}
 	err := ws.Load(ctx, workspacePath)
@@ -16,5 +19,12 @@ func handleDetectToolchains(ctx context.Context, workspacePath string, args []st
 		return fmt.Errorf("error loading workspace: %w", err)
 	}
 
-	return ws.DetectToolchains(ctx)
+	opts := ccommon.DetectOptions{
+		DetectLauncher: cli.GetBool(ctx, cli.FlagKey(ccommon.FlagLauncher)),
+	}
+	if searchDirs := cli.GetString(ctx, cli.FlagKey(ccommon.FlagSearchDir)); searchDirs != "" {
+		opts.SearchDirs = strings.Split(searchDirs, ",")
+	}
+
+	return ws.DetectToolchains(ctx, opts)
 }
@@ -10,7 +10,7 @@ import (
 
 func handleGetArgs(ctx context.Context, workspacePath string, args []string) error {
 	if len(args) != 1 {
-		return fmt.Errorf("usage: csetup get-args <target> [-T|--toolchain <toolchain>] [-c|--config <type>]")
+		return fmt.Errorf("usage: csetup get-args <target> [-T|--toolchain <toolchain>] [-c|--config <type>] [-V|--variant <variant>]")
 	}
 
 	targetName := args[0]
@@ -22,16 +22,33 @@ func handleGetArgs(ctx context.Context, workspacePath string, args []string) err
 	if buildType == "" {
 		buildType = "Debug"
 	}
+	err := ccommon.ValidateConfigurationName(buildType)
+	if err != nil {
+		return err
+	}
 
 	ws := &ccommon.WorkspaceContext{}
-	err := ws.Load(ctx, workspacePath)
+	err = ws.Load(ctx, workspacePath)
 	if err != nil {
 		return fmt.Errorf("error loading workspace: %w", err)
 	}
 
+	variant := cli.GetString(ctx, cli.FlagKey(ccommon.FlagVariant))
+	if variant != "" {
+		if _, ok := ws.Config.Variants[variant]; !ok {
+			return fmt.Errorf("variant %s not found in workspace", variant)
+		}
+	}
+
 	bp := ccommon.TargetBuildParameters{
 		Toolchain: toolchain,
 		BuildType: buildType,
+		Variant:   variant,
+	}
+
+	targetName, err = ws.ResolveTarget(ctx, targetName)
+	if err != nil {
+		return err
 	}
 
 	filteredArgs, err := ws.GetBuildArgs(ctx, targetName, bp)
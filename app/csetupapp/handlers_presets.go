@@ -0,0 +1,55 @@
+package csetupapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/ccommon"
+	"gitlab.com/rpnx/cbuild-go/pkg/cli"
+)
+
+func handleGenPresets(ctx context.Context, workspacePath string, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: csetup gen-presets [target] [-T|--toolchain <toolchains>] [-c|--config <configs>] [-V|--variant <variants>] [--force]")
+	}
+
+	ws := &ccommon.WorkspaceContext{}
+	err := ws.Load(ctx, workspacePath)
+	if err != nil {
+		return fmt.Errorf("error loading workspace: %w", err)
+	}
+
+	targets := ws.ListTargets(ctx)
+	if len(args) == 1 {
+		targets, err = ws.ResolveTargets(ctx, args[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	var toolchains []string
+	toolchainFlag := cli.GetString(ctx, cli.FlagKey(ccommon.FlagToolchain))
+	if toolchainFlag == "" || toolchainFlag == "all" {
+		toolchains, err = ws.ListToolchains(ctx)
+		if err != nil {
+			return fmt.Errorf("error listing toolchains: %w", err)
+		}
+	} else {
+		toolchains = strings.Split(toolchainFlag, ",")
+	}
+
+	configs, err := ws.ResolveConfigurations(cli.GetString(ctx, cli.FlagKey(ccommon.FlagConfig)))
+	if err != nil {
+		return err
+	}
+
+	variants, err := ws.ResolveVariants(cli.GetString(ctx, cli.FlagKey(ccommon.FlagVariant)))
+	if err != nil {
+		return err
+	}
+
+	force := cli.GetBool(ctx, cli.FlagKey(ccommon.FlagForce))
+
+	return ws.GeneratePresets(ctx, targets, toolchains, configs, variants, force)
+}
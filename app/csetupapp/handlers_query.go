@@ -0,0 +1,70 @@
+package csetupapp
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/ccommon"
+)
+
+func loadQueryWorkspace(ctx context.Context, workspacePath string) (*ccommon.WorkspaceContext, error) {
+	ws := &ccommon.WorkspaceContext{}
+	err := ws.Load(ctx, workspacePath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading workspace: %w", err)
+	}
+	return ws, nil
+}
+
+func handleWhy(ctx context.Context, workspacePath string, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: csetup why <target> <dependency>")
+	}
+	ws, err := loadQueryWorkspace(ctx, workspacePath)
+	if err != nil {
+		return err
+	}
+	from, err := ws.ResolveTarget(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	to, err := ws.ResolveTarget(ctx, args[1])
+	if err != nil {
+		return err
+	}
+
+	return ws.WriteWhy(ctx, os.Stdout, from, to)
+}
+
+func handleRdeps(ctx context.Context, workspacePath string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: csetup rdeps <target>")
+	}
+	ws, err := loadQueryWorkspace(ctx, workspacePath)
+	if err != nil {
+		return err
+	}
+	targetName, err := ws.ResolveTarget(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	return ws.WriteRdeps(ctx, os.Stdout, targetName)
+}
+
+func handleTree(ctx context.Context, workspacePath string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: csetup tree <target>")
+	}
+	ws, err := loadQueryWorkspace(ctx, workspacePath)
+	if err != nil {
+		return err
+	}
+	targetName, err := ws.ResolveTarget(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	return ws.WriteTree(ctx, os.Stdout, targetName)
+}
@@ -0,0 +1,166 @@
+package csetupapp
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/ccommon"
+	"gitlab.com/rpnx/cbuild-go/pkg/cli"
+	"gitlab.com/rpnx/cbuild-go/pkg/cmake"
+	"gitlab.com/rpnx/cbuild-go/pkg/host"
+	"gitlab.com/rpnx/cbuild-go/pkg/system"
+
+	"gopkg.in/yaml.v3"
+)
+
+const toolchainUsage = "usage: csetup toolchain <list|show|add|remove|rename|copy> [name] [new name]"
+
+func handleToolchain(ctx context.Context, workspacePath string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(toolchainUsage)
+	}
+
+	ws := &ccommon.WorkspaceContext{}
+	err := ws.Load(ctx, workspacePath)
+	if err != nil {
+		return fmt.Errorf("error loading workspace: %w", err)
+	}
+
+	action, args := args[0], args[1:]
+	switch action {
+	case "list":
+		if len(args) != 0 {
+			return fmt.Errorf("usage: csetup toolchain list")
+		}
+		return handleToolchainList(ctx, ws)
+	case "show":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: csetup toolchain show <name>")
+		}
+		return handleToolchainShow(ctx, ws, args[0])
+	case "add":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: csetup toolchain add <name> [--cc <compiler>] [--cxx <compiler>] [--cflags <flags>] [--cxxflags <flags>] [--ldflags <flags>] [--linker <type>] [--system <system>] [--arch <arch>] [--host <host key>] [--toolchain-file <file>] [--force]")
+		}
+		return handleToolchainAdd(ctx, ws, args[0])
+	case "remove":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: csetup toolchain remove <name> [-X|--delete]")
+		}
+		return ws.RemoveToolchain(ctx, args[0], cli.GetBool(ctx, cli.FlagKey(ccommon.FlagDelete)))
+	case "rename":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: csetup toolchain rename <name> <new name>")
+		}
+		return ws.RenameToolchain(ctx, args[0], args[1])
+	case "copy":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: csetup toolchain copy <name> <new name>")
+		}
+		return ws.CopyToolchain(ctx, args[0], args[1])
+	}
+	return fmt.Errorf(toolchainUsage)
+}
+
+func handleToolchainList(ctx context.Context, ws *ccommon.WorkspaceContext) error {
+	infos, err := ws.ToolchainInfos(ctx)
+	if err != nil {
+		return err
+	}
+	if len(infos) == 0 {
+		fmt.Println("No toolchains found, run csetup detect-toolchains or csetup toolchain add")
+		return nil
+	}
+
+	hostKey := ccommon.HostToolchainKey()
+	for _, info := range infos {
+		availability := "not available on " + hostKey
+		if info.AvailableOnHost {
+			availability = "available"
+		}
+		fmt.Printf("%-30s %s/%s  %s  (hosts: %s)\n", info.Name, info.Toolchain.TargetSystem.StringLower(), info.Toolchain.TargetArch.StringLower(), availability, strings.Join(info.Hosts, ", "))
+	}
+	return nil
+}
+
+func handleToolchainShow(ctx context.Context, ws *ccommon.WorkspaceContext, name string) error {
+	tc, _, err := ws.LoadToolchain(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(tc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal toolchain %s: %w", name, err)
+	}
+
+	content, err := ws.HostToolchainFile(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if tc.Extends != "" {
+		fmt.Printf("# toolchains/%s/toolchain.yml, merged with the toolchains it extends\n%s\n", name, data)
+	} else {
+		fmt.Printf("# toolchains/%s/toolchain.yml\n%s\n", name, data)
+	}
+	fmt.Printf("# CMake toolchain file for %s\n%s", ccommon.HostToolchainKey(), content)
+	return nil
+}
+
+func handleToolchainAdd(ctx context.Context, ws *ccommon.WorkspaceContext, name string) error {
+	tc := ccommon.Toolchain{
+		TargetSystem: host.DetectHostPlatform(),
+		TargetArch:   host.DetectHostProcessor(),
+	}
+
+	var err error
+	if s := cli.GetString(ctx, cli.FlagKey(ccommon.FlagSystem)); s != "" {
+		tc.TargetSystem, err = system.ParsePlatform(s)
+		if err != nil {
+			return err
+		}
+	}
+	if s := cli.GetString(ctx, cli.FlagKey(ccommon.FlagArch)); s != "" {
+		tc.TargetArch, err = system.ParseProcessor(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	hostKey := cli.GetString(ctx, cli.FlagKey(ccommon.FlagHost))
+	if hostKey == "" {
+		hostKey = ccommon.HostToolchainKey()
+	}
+
+	var opts ccommon.CMakeToolchainOptions
+	if file := cli.GetString(ctx, cli.FlagKey(ccommon.FlagToolchainFile)); file != "" {
+		opts.CMakeToolchainFile, err = filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+	} else {
+		gen := &ccommon.CMakeGenerateToolchainFileOptions{
+			CCompiler:        cli.GetString(ctx, cli.FlagKey(ccommon.FlagCC)),
+			CXXCompiler:      cli.GetString(ctx, cli.FlagKey(ccommon.FlagCXX)),
+			ExtraCFlags:      strings.Fields(cli.GetString(ctx, cli.FlagKey(ccommon.FlagCFlags))),
+			ExtraCXXFlags:    strings.Fields(cli.GetString(ctx, cli.FlagKey(ccommon.FlagCXXFlags))),
+			ExtraLinkerFlags: strings.Fields(cli.GetString(ctx, cli.FlagKey(ccommon.FlagLDFlags))),
+		}
+		if gen.CCompiler == "" && gen.CXXCompiler == "" {
+			return fmt.Errorf("either --cc/--cxx or --toolchain-file is required")
+		}
+
+		if linker := cli.GetString(ctx, cli.FlagKey(ccommon.FlagLinkerType)); linker != "" {
+			gen.LinkerType, err = cmake.ParseLinkerType(linker)
+			if err != nil {
+				return err
+			}
+		}
+		opts.Generate = gen
+	}
+
+	return ws.AddToolchainHost(ctx, name, tc, hostKey, opts, cli.GetBool(ctx, cli.FlagKey(ccommon.FlagForce)))
+}
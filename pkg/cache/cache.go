@@ -0,0 +1,748 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Cache is a local content-addressed store of directory archives. Each entry
+// is stored as <dir>/<key[:2]>/<key>.tar.gz with a <key>.yml metadata file.
+type Cache struct {
+	Dir string
+}
+
+type Entry struct {
+	Key    string `yaml:"key"`
+	Target string `yaml:"target,omitempty"`
+
+	// Prefix is the absolute path that archived text files may refer to. It is
+	// rewritten to the new prefix when the entry is restored elsewhere.
+	Prefix string `yaml:"prefix,omitempty"`
+
+	// Checksum is the sha256 of the archive file.
+	Checksum string    `yaml:"checksum"`
+	Size     int64     `yaml:"size"`
+	Created  time.Time `yaml:"created"`
+	LastUsed time.Time `yaml:"last_used"`
+}
+
+type Counters struct {
+	Hits   int64 `yaml:"hits"`
+	Misses int64 `yaml:"misses"`
+}
+
+type Stats struct {
+	Counters
+	Entries   int
+	TotalSize int64
+}
+
+// DefaultDir returns the per-user cache directory, e.g. ~/.cache/cbuild.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "cbuild"), nil
+}
+
+func New(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+func validKey(key string) error {
+	if len(key) < 3 || strings.ContainsAny(key, "/\\.") {
+		return fmt.Errorf("invalid cache key %q", key)
+	}
+	return nil
+}
+
+func (c *Cache) ArchivePath(key string) string {
+	return filepath.Join(c.Dir, key[:2], key+".tar.gz")
+}
+
+func (c *Cache) MetaPath(key string) string {
+	return filepath.Join(c.Dir, key[:2], key+".yml")
+}
+
+func (c *Cache) countersPath() string {
+	return filepath.Join(c.Dir, "stats.yml")
+}
+
+// Lookup returns the entry for key, or nil if it is not in the cache.
+func (c *Cache) Lookup(key string) (*Entry, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(c.MetaPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+
+	entry := &Entry{}
+	err = yaml.Unmarshal(data, entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cache entry %s: %w", key, err)
+	}
+
+	if _, err := os.Stat(c.ArchivePath(key)); err != nil {
+		return nil, nil
+	}
+
+	return entry, nil
+}
+
+// Store archives srcDir under key. The entry's Key, Checksum, Size and
+// timestamps are filled in by Store.
+func (c *Cache) Store(key string, srcDir string, entry Entry) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	archivePath := c.ArchivePath(key)
+	err := os.MkdirAll(filepath.Dir(archivePath), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(archivePath), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary archive: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	err = WriteArchive(io.MultiWriter(tmp, hasher), srcDir)
+	closeErr := tmp.Close()
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", srcDir, err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to write archive: %w", closeErr)
+	}
+
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	entry.Key = key
+	entry.Checksum = hex.EncodeToString(hasher.Sum(nil))
+	entry.Size = info.Size()
+	entry.Created = now
+	entry.LastUsed = now
+
+	err = os.Rename(tmp.Name(), archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to move archive into cache: %w", err)
+	}
+
+	return c.WriteEntry(&entry)
+}
+
+// WriteEntry writes the metadata file for an entry.
+func (c *Cache) WriteEntry(entry *Entry) error {
+	data, err := yaml.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	metaPath := c.MetaPath(entry.Key)
+	err = os.MkdirAll(filepath.Dir(metaPath), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	err = os.WriteFile(metaPath, data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces destDir with the contents of the entry for key. If the
+// entry was stored with a prefix, occurrences of it in text files are
+// rewritten to newPrefix.
+func (c *Cache) Restore(key string, destDir string, newPrefix string) error {
+	entry, err := c.Lookup(key)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("cache entry %s not found", key)
+	}
+
+	err = VerifyFile(c.ArchivePath(key), entry.Checksum)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(c.ArchivePath(key))
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	err = os.RemoveAll(destDir)
+	if err != nil {
+		return fmt.Errorf("failed to clear %s: %w", destDir, err)
+	}
+
+	err = ExtractArchive(f, destDir)
+	if err != nil {
+		os.RemoveAll(destDir)
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+
+	if entry.Prefix != "" && newPrefix != "" && entry.Prefix != newPrefix {
+		err = Relocate(destDir, entry.Prefix, newPrefix)
+		if err != nil {
+			return fmt.Errorf("failed to relocate %s: %w", destDir, err)
+		}
+	}
+
+	entry.LastUsed = time.Now()
+	return c.WriteEntry(entry)
+}
+
+func (c *Cache) readCounters() (Counters, error) {
+	var counters Counters
+	data, err := os.ReadFile(c.countersPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return counters, nil
+		}
+		return counters, err
+	}
+	err = yaml.Unmarshal(data, &counters)
+	return counters, err
+}
+
+func (c *Cache) updateCounters(update func(*Counters)) error {
+	counters, err := c.readCounters()
+	if err != nil {
+		return fmt.Errorf("failed to read cache stats: %w", err)
+	}
+	update(&counters)
+
+	data, err := yaml.Marshal(counters)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(c.Dir, 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.countersPath(), data, 0644)
+}
+
+func (c *Cache) RecordHit() error {
+	return c.updateCounters(func(counters *Counters) { counters.Hits++ })
+}
+
+func (c *Cache) RecordMiss() error {
+	return c.updateCounters(func(counters *Counters) { counters.Misses++ })
+}
+
+// Entries returns all entries in the cache, least recently used first.
+func (c *Cache) Entries() ([]*Entry, error) {
+	var entries []*Entry
+
+	err := filepath.WalkDir(c.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".yml") || path == c.countersPath() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		entry := &Entry{}
+		if err := yaml.Unmarshal(data, entry); err != nil {
+			return fmt.Errorf("failed to parse cache entry %s: %w", path, err)
+		}
+		if entry.Key != "" {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+	return entries, nil
+}
+
+func (c *Cache) Stats() (Stats, error) {
+	var stats Stats
+
+	counters, err := c.readCounters()
+	if err != nil {
+		return stats, fmt.Errorf("failed to read cache stats: %w", err)
+	}
+	stats.Counters = counters
+
+	entries, err := c.Entries()
+	if err != nil {
+		return stats, err
+	}
+	stats.Entries = len(entries)
+	for _, entry := range entries {
+		stats.TotalSize += entry.Size
+	}
+	return stats, nil
+}
+
+func (c *Cache) Remove(key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(c.ArchivePath(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Remove(c.MetaPath(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Prune removes entries that have not been used within maxAge, then removes
+// least recently used entries until the cache is no larger than maxSize. A
+// zero maxAge or maxSize disables that limit.
+func (c *Cache) Prune(maxAge time.Duration, maxSize int64) (int, int64, error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+
+	removed := 0
+	var freed int64
+	now := time.Now()
+	for _, entry := range entries {
+		expired := maxAge > 0 && now.Sub(entry.LastUsed) > maxAge
+		oversized := maxSize > 0 && total > maxSize
+		if !expired && !oversized {
+			continue
+		}
+
+		err := c.Remove(entry.Key)
+		if err != nil {
+			return removed, freed, fmt.Errorf("failed to remove cache entry %s: %w", entry.Key, err)
+		}
+		removed++
+		freed += entry.Size
+		total -= entry.Size
+	}
+
+	return removed, freed, nil
+}
+
+// WriteArchive writes the contents of dir as a gzip-compressed tarball.
+func WriteArchive(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			// ExtractArchive refuses such links, refuse to store them too.
+			if err := checkLinkTarget(rel, link); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ExtractArchive extracts a tarball written by WriteArchive into destDir.
+// Entries may not escape destDir, neither by their name, by the target of a
+// symlink, nor by being written through a symlink extracted before them.
+func ExtractArchive(r io.Reader, destDir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	err = os.MkdirAll(destDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.FromSlash(hdr.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("archive entry %q escapes destination", hdr.Name)
+		}
+		target := filepath.Join(destDir, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := mkdirNoSymlinks(destDir, name); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := checkLinkTarget(name, hdr.Linkname); err != nil {
+				return err
+			}
+			if err := mkdirNoSymlinks(destDir, filepath.Dir(name)); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := mkdirNoSymlinks(destDir, filepath.Dir(name)); err != nil {
+				return err
+			}
+			if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				return fmt.Errorf("archive entry %q would be written through a symlink", hdr.Name)
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&os.ModePerm)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			closeErr := f.Close()
+			if err != nil {
+				return err
+			}
+			if closeErr != nil {
+				return closeErr
+			}
+		default:
+			return fmt.Errorf("unsupported archive entry type for %q", hdr.Name)
+		}
+	}
+}
+
+// checkLinkTarget returns an error if the symlink name, relative to the
+// archive root, is absolute or points outside of the archive root.
+func checkLinkTarget(name string, link string) error {
+	if link == "" || filepath.IsAbs(link) || filepath.VolumeName(link) != "" {
+		return fmt.Errorf("symlink %q points to %q, outside of the archive", filepath.ToSlash(name), link)
+	}
+	resolved := filepath.Join(filepath.Dir(name), filepath.FromSlash(link))
+	if !filepath.IsLocal(resolved) {
+		return fmt.Errorf("symlink %q points to %q, outside of the archive", filepath.ToSlash(name), link)
+	}
+	return nil
+}
+
+// mkdirNoSymlinks creates the directory rel under root and its parents. It
+// fails if rel or one of its parents exists as a symlink or a file, so that
+// nothing is created through a symlink.
+func mkdirNoSymlinks(root string, rel string) error {
+	if rel == "." {
+		return nil
+	}
+
+	dir := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			if err := os.Mkdir(dir, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink, refusing to extract through it", dir)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+	}
+	return nil
+}
+
+// Relocate rewrites occurrences of oldPrefix with newPrefix in every text
+// file under dir. Files containing NUL bytes are treated as binary and left
+// untouched.
+func Relocate(dir string, oldPrefix string, newPrefix string) error {
+	oldBytes := []byte(oldPrefix)
+	newBytes := []byte(newPrefix)
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.IndexByte(data, 0) != -1 || !bytes.Contains(data, oldBytes) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, bytes.ReplaceAll(data, oldBytes, newBytes), info.Mode().Perm())
+	})
+}
+
+// HashDirectory returns a sha256 over the relative paths, modes and contents
+// of every file under dir. Directories named in skip are not descended into.
+func HashDirectory(dir string, skip ...string) (string, error) {
+	hasher := sha256.New()
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			for _, s := range skip {
+				if d.Name() == s && path != dir {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hasher, "%s\x00%o\x00", filepath.ToSlash(rel), info.Mode())
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			hasher.Write([]byte(link))
+		} else if info.Mode().IsRegular() {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(hasher, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+		hasher.Write([]byte{0})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// HashStrings returns the hex sha256 of the given strings, separated by newlines.
+func HashStrings(parts ...string) string {
+	hasher := sha256.New()
+	for _, part := range parts {
+		hasher.Write([]byte(part))
+		hasher.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// ErrChecksumMismatch is returned when an archive does not match its recorded checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// VerifyFile checks that the sha256 of the file at path matches checksum.
+func VerifyFile(path string, checksum string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	actual := hex.EncodeToString(hasher.Sum(nil))
+	if actual != checksum {
+		return fmt.Errorf("%s: %w (expected %s, got %s)", path, ErrChecksumMismatch, checksum, actual)
+	}
+	return nil
+}
+
+// ParseSize parses a byte count with an optional K, M, G or T suffix (powers of 1024).
+func ParseSize(size string) (int64, error) {
+	s := strings.TrimSpace(strings.ToUpper(size))
+	s = strings.TrimSuffix(s, "B")
+	multiplier := int64(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier != 1 {
+			s = s[:len(s)-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err == nil && n >= 0 {
+		if n > math.MaxInt64/multiplier {
+			return 0, fmt.Errorf("size %q is too large", size)
+		}
+		return n * multiplier, nil
+	}
+
+	// Fractions such as 1.5G are accepted with a unit.
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || multiplier == 1 || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	// MaxInt64 is not exactly representable, the largest float64 below it
+	// is the bound.
+	total := f * float64(multiplier)
+	if total >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", size)
+	}
+	return int64(total), nil
+}
+
+// ParseAge parses a duration, additionally accepting a "d" suffix for days.
+func ParseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseInt(days, 10, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		if n > int64(math.MaxInt64/(24*time.Hour)) {
+			return 0, fmt.Errorf("age %q is too large", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// FormatSize formats a byte count for display.
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,334 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testArchiveEntry is an entry of an archive built by writeTestArchive.
+type testArchiveEntry struct {
+	name    string
+	link    string
+	content string
+	dir     bool
+}
+
+// writeTestArchive returns a gzip-compressed tarball of entries, which need
+// not be valid.
+func writeTestArchive(t *testing.T, entries []testArchiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.content))}
+		switch {
+		case e.dir:
+			hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0755, 0
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractArchive(t *testing.T) {
+	tmp := t.TempDir()
+	dest := filepath.Join(tmp, "dest")
+	archive := writeTestArchive(t, []testArchiveEntry{
+		{name: "lib", dir: true},
+		{name: "lib/libfoo.so.1", content: "elf"},
+		{name: "lib/libfoo.so", link: "libfoo.so.1"},
+		{name: "lib/cmake/foo/foo-config.cmake", content: "cmake"},
+		{name: "share/foo/lib", link: "../../lib"},
+	})
+
+	if err := ExtractArchive(bytes.NewReader(archive), dest); err != nil {
+		t.Fatalf("ExtractArchive() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "share", "foo", "lib", "libfoo.so"))
+	if err != nil || string(data) != "elf" {
+		t.Errorf("reading through the extracted symlinks = %q, %v, want elf", data, err)
+	}
+}
+
+func TestExtractArchiveRejectsEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []testArchiveEntry
+	}{
+		{"parent name", []testArchiveEntry{{name: "../victim", content: "owned"}}},
+		{"absolute name", []testArchiveEntry{{name: "/victim", content: "owned"}}},
+		{"absolute link", []testArchiveEntry{{name: "lib", link: "OUTSIDE"}, {name: "lib/victim", content: "owned"}}},
+		{"relative link", []testArchiveEntry{{name: "lib", link: "../outside"}, {name: "lib/victim", content: "owned"}}},
+		{"nested link", []testArchiveEntry{{name: "a/b/lib", link: "../../../outside"}}},
+		{"link then file", []testArchiveEntry{{name: "victim", link: "OUTSIDE/victim"}, {name: "victim", content: "owned"}}},
+		// Links within the archive are fine, but nothing is extracted through them.
+		{"write through local link", []testArchiveEntry{{name: "real", dir: true}, {name: "lib", link: "real"}, {name: "lib/file", content: "x"}}},
+		{"file through local link", []testArchiveEntry{{name: "real", content: "x"}, {name: "lib", link: "real"}, {name: "lib", content: "y"}}},
+		{"dir through local link", []testArchiveEntry{{name: "real", dir: true}, {name: "lib", link: "real"}, {name: "lib/sub", dir: true}}},
+	}
+
+	for _, tt := range tests {
+		tmp := t.TempDir()
+		outside := filepath.Join(tmp, "outside")
+		writeFile(t, filepath.Join(outside, "victim"), "original")
+
+		var entries []testArchiveEntry
+		for _, e := range tt.entries {
+			e.link = strings.ReplaceAll(e.link, "OUTSIDE", outside)
+			entries = append(entries, e)
+		}
+
+		dest := filepath.Join(tmp, "dest")
+		if err := ExtractArchive(bytes.NewReader(writeTestArchive(t, entries)), dest); err == nil {
+			t.Errorf("%s: ExtractArchive() = nil, want an error", tt.name)
+		}
+
+		data, err := os.ReadFile(filepath.Join(outside, "victim"))
+		if err != nil || string(data) != "original" {
+			t.Errorf("%s: file outside the destination = %q, %v, want it untouched", tt.name, data, err)
+		}
+		if entries, _ := os.ReadDir(outside); len(entries) != 1 {
+			t.Errorf("%s: ExtractArchive() created %d entries outside the destination", tt.name, len(entries)-1)
+		}
+	}
+}
+
+func TestWriteArchiveRejectsEscapingLinks(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src")
+	writeFile(t, filepath.Join(src, "a.txt"), "a")
+	if err := os.Symlink(filepath.Join(tmp, "elsewhere"), filepath.Join(src, "abs")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteArchive(&bytes.Buffer{}, src); err == nil {
+		t.Errorf("WriteArchive() with an absolute symlink = nil, want an error")
+	}
+}
+
+func TestStoreRestore(t *testing.T) {
+	tmp := t.TempDir()
+	c := New(filepath.Join(tmp, "cache"))
+
+	src := filepath.Join(tmp, "old", "staging")
+	writeFile(t, filepath.Join(src, "lib", "pkgconfig", "foo.pc"), "prefix="+filepath.Join(tmp, "old")+"/staging\n")
+	writeFile(t, filepath.Join(src, "lib", "libfoo.a"), "bin\x00"+filepath.Join(tmp, "old"))
+	if err := os.Symlink("libfoo.a", filepath.Join(src, "lib", "libfoo.so")); err != nil {
+		t.Fatal(err)
+	}
+
+	key := HashStrings("foo")
+	entry, err := c.Lookup(key)
+	if err != nil || entry != nil {
+		t.Fatalf("Lookup before Store = %v, %v, want nil, nil", entry, err)
+	}
+
+	err = c.Store(key, src, Entry{Target: "foo", Prefix: filepath.Join(tmp, "old")})
+	if err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	dest := filepath.Join(tmp, "new", "staging")
+	err = c.Restore(key, dest, filepath.Join(tmp, "new"))
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	pc, err := os.ReadFile(filepath.Join(dest, "lib", "pkgconfig", "foo.pc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "prefix=" + filepath.Join(tmp, "new") + "/staging\n"; string(pc) != want {
+		t.Errorf("relocated foo.pc = %q, want %q", pc, want)
+	}
+
+	bin, err := os.ReadFile(filepath.Join(dest, "lib", "libfoo.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "bin\x00" + filepath.Join(tmp, "old"); string(bin) != want {
+		t.Errorf("binary file was modified: %q", bin)
+	}
+
+	link, err := os.Readlink(filepath.Join(dest, "lib", "libfoo.so"))
+	if err != nil || link != "libfoo.a" {
+		t.Errorf("symlink = %q, %v, want libfoo.a", link, err)
+	}
+
+	h1, err := HashDirectory(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := HashDirectory(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 != h2 {
+		t.Errorf("HashDirectory is not stable: %s != %s", h1, h2)
+	}
+	writeFile(t, filepath.Join(src, "lib", "extra.txt"), "x")
+	h3, err := HashDirectory(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h3 == h1 {
+		t.Errorf("HashDirectory did not change after adding a file")
+	}
+}
+
+func TestRestoreChecksumMismatch(t *testing.T) {
+	tmp := t.TempDir()
+	c := New(filepath.Join(tmp, "cache"))
+
+	src := filepath.Join(tmp, "src")
+	writeFile(t, filepath.Join(src, "a.txt"), "a")
+
+	key := HashStrings("a")
+	if err := c.Store(key, src, Entry{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.ArchivePath(key), []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Restore(key, filepath.Join(tmp, "dest"), ""); err == nil {
+		t.Errorf("Restore() of corrupted archive succeeded")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tmp := t.TempDir()
+	c := New(filepath.Join(tmp, "cache"))
+
+	src := filepath.Join(tmp, "src")
+	writeFile(t, filepath.Join(src, "a.txt"), "a")
+
+	oldKey := HashStrings("old")
+	newKey := HashStrings("new")
+	for _, key := range []string{oldKey, newKey} {
+		if err := c.Store(key, src, Entry{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entry, err := c.Lookup(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.LastUsed = time.Now().Add(-48 * time.Hour)
+	if err := c.WriteEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, _, err := c.Prune(24*time.Hour, 0)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Prune() removed %d entries, want 1", removed)
+	}
+
+	if entry, _ := c.Lookup(oldKey); entry != nil {
+		t.Errorf("expired entry was not pruned")
+	}
+	if entry, _ := c.Lookup(newKey); entry == nil {
+		t.Errorf("recent entry was pruned")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"100", 100, false},
+		{"10K", 10 << 10, false},
+		{"2g", 2 << 30, false},
+		{"5MB", 5 << 20, false},
+		{"1.5G", 3 << 29, false},
+		{"lots", 0, true},
+		{"10X", 0, true},
+		{"10 GB extra", 0, true},
+		{"1.5", 0, true},
+		{"-1K", 0, true},
+		{"", 0, true},
+		{"8388607T", 8388607 << 40, false},
+		{"8388608T", 0, true},
+		{"9000000000T", 0, true},
+		{"9223372036854775807", 9223372036854775807, false},
+		{"9223372036854775808", 0, true},
+		{"8388607.5T", 8388607<<40 + 1<<39, false},
+		{"8388608.5T", 0, true},
+		{"1e30K", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"12h", 12 * time.Hour, false},
+		{"3xd", 0, true},
+		{"-1d", 0, true},
+		{"106751d", 106751 * 24 * time.Hour, false},
+		{"106752d", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAge(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
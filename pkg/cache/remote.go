@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Remote is a cache shared over plain HTTP. Entries use the same layout as the
+// local cache: GET/PUT <url>/<key[:2]>/<key>.tar.gz and <key>.yml.
+type Remote struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewRemote(url string) *Remote {
+	return &Remote{
+		URL:    strings.TrimSuffix(url, "/"),
+		Client: &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+func (r *Remote) archiveURL(key string) string {
+	return fmt.Sprintf("%s/%s/%s.tar.gz", r.URL, key[:2], key)
+}
+
+func (r *Remote) metaURL(key string) string {
+	return fmt.Sprintf("%s/%s/%s.yml", r.URL, key[:2], key)
+}
+
+func (r *Remote) get(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (r *Remote) put(ctx context.Context, url string, body io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("PUT %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// Fetch downloads the entry for key into the local cache. It returns false if
+// the remote does not have the entry. The archive is verified against the
+// checksum in the entry metadata before it is added to the local cache.
+func (r *Remote) Fetch(ctx context.Context, key string, local *Cache) (bool, error) {
+	if err := validKey(key); err != nil {
+		return false, err
+	}
+
+	metaBody, err := r.get(ctx, r.metaURL(key))
+	if err != nil {
+		return false, err
+	}
+	if metaBody == nil {
+		return false, nil
+	}
+	metaData, err := io.ReadAll(metaBody)
+	metaBody.Close()
+	if err != nil {
+		return false, fmt.Errorf("failed to download cache entry: %w", err)
+	}
+
+	entry := &Entry{}
+	err = yaml.Unmarshal(metaData, entry)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse remote cache entry %s: %w", key, err)
+	}
+	if entry.Key != key || entry.Checksum == "" {
+		return false, fmt.Errorf("remote cache entry %s is invalid", key)
+	}
+
+	archiveBody, err := r.get(ctx, r.archiveURL(key))
+	if err != nil {
+		return false, err
+	}
+	if archiveBody == nil {
+		return false, nil
+	}
+	defer archiveBody.Close()
+
+	archivePath := local.ArchivePath(key)
+	err = os.MkdirAll(filepath.Dir(archivePath), 0755)
+	if err != nil {
+		return false, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(archivePath), key+".*.tmp")
+	if err != nil {
+		return false, fmt.Errorf("failed to create temporary archive: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, archiveBody)
+	closeErr := tmp.Close()
+	if err != nil {
+		return false, fmt.Errorf("failed to download archive: %w", err)
+	}
+	if closeErr != nil {
+		return false, fmt.Errorf("failed to write archive: %w", closeErr)
+	}
+
+	err = VerifyFile(tmp.Name(), entry.Checksum)
+	if err != nil {
+		return false, err
+	}
+
+	err = os.Rename(tmp.Name(), archivePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to move archive into cache: %w", err)
+	}
+
+	entry.LastUsed = time.Now()
+	err = local.WriteEntry(entry)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Upload copies the local entry for key to the remote. The archive is uploaded
+// before the metadata, so readers never see metadata without an archive.
+func (r *Remote) Upload(ctx context.Context, key string, local *Cache) error {
+	entry, err := local.Lookup(key)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("cache entry %s not found", key)
+	}
+
+	f, err := os.Open(local.ArchivePath(key))
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	err = r.put(ctx, r.archiveURL(key), f, entry.Size)
+	if err != nil {
+		return err
+	}
+
+	metaData, err := yaml.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+	return r.put(ctx, r.metaURL(key), bytes.NewReader(metaData), int64(len(metaData)))
+}
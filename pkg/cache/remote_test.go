@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoteUploadFetch(t *testing.T) {
+	tmp := t.TempDir()
+	server := httptest.NewServer(Handler(filepath.Join(tmp, "server"), false, 0))
+	defer server.Close()
+
+	ctx := context.Background()
+	remote := NewRemote(server.URL)
+
+	src := filepath.Join(tmp, "src")
+	writeFile(t, filepath.Join(src, "include", "foo.h"), "#pragma once\n")
+
+	key := HashStrings("foo")
+	uploader := New(filepath.Join(tmp, "uploader"))
+	if err := uploader.Store(key, src, Entry{Target: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Upload(ctx, key, uploader); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	downloader := New(filepath.Join(tmp, "downloader"))
+	found, err := remote.Fetch(ctx, HashStrings("missing"), downloader)
+	if err != nil || found {
+		t.Errorf("Fetch() of missing key = %v, %v, want false, nil", found, err)
+	}
+
+	found, err = remote.Fetch(ctx, key, downloader)
+	if err != nil || !found {
+		t.Fatalf("Fetch() = %v, %v, want true, nil", found, err)
+	}
+
+	dest := filepath.Join(tmp, "dest")
+	if err := downloader.Restore(key, dest, ""); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "include", "foo.h")); err != nil {
+		t.Errorf("restored file missing: %v", err)
+	}
+}
+
+func TestRemoteRejectsCorruptArchive(t *testing.T) {
+	tmp := t.TempDir()
+	serverDir := filepath.Join(tmp, "server")
+	server := httptest.NewServer(Handler(serverDir, false, 0))
+	defer server.Close()
+
+	ctx := context.Background()
+	remote := NewRemote(server.URL)
+
+	src := filepath.Join(tmp, "src")
+	writeFile(t, filepath.Join(src, "a.txt"), "a")
+
+	key := HashStrings("a")
+	local := New(filepath.Join(tmp, "local"))
+	if err := local.Store(key, src, Entry{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Upload(ctx, key, local); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(New(serverDir).ArchivePath(key), []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := remote.Fetch(ctx, key, New(filepath.Join(tmp, "other")))
+	if err == nil || found {
+		t.Errorf("Fetch() of corrupt archive = %v, %v, want checksum error", found, err)
+	}
+}
+
+// A hostile server can serve a matching checksum for any archive, the
+// archive itself must not be able to write outside of the restored directory.
+func TestRemoteRejectsHostileArchive(t *testing.T) {
+	tmp := t.TempDir()
+	outside := filepath.Join(tmp, "home")
+	writeFile(t, filepath.Join(outside, ".bashrc"), "original")
+
+	archive := writeTestArchive(t, []testArchiveEntry{
+		{name: "lib", link: outside},
+		{name: "lib/.bashrc", content: "owned"},
+	})
+	sum := sha256.Sum256(archive)
+
+	key := HashStrings("hostile")
+	serverDir := filepath.Join(tmp, "server")
+	hostile := New(serverDir)
+	writeFile(t, hostile.ArchivePath(key), string(archive))
+	if err := hostile.WriteEntry(&Entry{Key: key, Checksum: hex.EncodeToString(sum[:])}); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(Handler(serverDir, true, 0))
+	defer server.Close()
+
+	ctx := context.Background()
+	local := New(filepath.Join(tmp, "local"))
+	found, err := NewRemote(server.URL).Fetch(ctx, key, local)
+	if err != nil || !found {
+		t.Fatalf("Fetch() = %v, %v, want the archive downloaded", found, err)
+	}
+
+	dest := filepath.Join(tmp, "dest")
+	if err := local.Restore(key, dest, ""); err == nil {
+		t.Errorf("Restore() of a hostile archive = nil, want an error")
+	}
+	data, err := os.ReadFile(filepath.Join(outside, ".bashrc"))
+	if err != nil || string(data) != "original" {
+		t.Errorf("file outside the workspace = %q, %v, want it untouched", data, err)
+	}
+	if _, err := os.Lstat(dest); !os.IsNotExist(err) {
+		t.Errorf("Restore() left %s behind after failing: %v", dest, err)
+	}
+}
+
+func TestHandlerReadOnly(t *testing.T) {
+	server := httptest.NewServer(Handler(t.TempDir(), true, 0))
+	defer server.Close()
+
+	key := HashStrings("a")
+	req, err := http.NewRequest(http.MethodPut, server.URL+"/"+key[:2]+"/"+key+".tar.gz", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("PUT to read-only server returned %s, want 403", resp.Status)
+	}
+
+	resp, err = http.Get(server.URL + "/../etc/passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET outside the cache returned %s, want 404", resp.Status)
+	}
+}
+
+func TestHandlerMaxUploadSize(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(Handler(dir, false, 4))
+	defer server.Close()
+
+	key := HashStrings("a")
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{"abcd", http.StatusCreated},
+		{"abcde", http.StatusRequestEntityTooLarge},
+	} {
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/"+key[:2]+"/"+key+".tar.gz", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("PUT of %d bytes returned %s, want %d", len(tt.body), resp.Status, tt.want)
+		}
+	}
+
+	data, err := os.ReadFile(New(dir).ArchivePath(key))
+	if err != nil || string(data) != "abcd" {
+		t.Errorf("stored archive = %q, %v, want the accepted upload", data, err)
+	}
+}
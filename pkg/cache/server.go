@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultMaxUploadSize is the largest file Handler accepts by default.
+const DefaultMaxUploadSize = 4 << 30
+
+// Handler serves a cache directory over HTTP using the protocol expected by
+// Remote. GET and HEAD serve files; PUT stores them unless readOnly is set.
+// Uploads larger than maxUploadSize bytes are rejected, 0 meaning
+// DefaultMaxUploadSize. Uploaded metadata is rejected unless its archive is
+// present and matches the recorded checksum.
+func Handler(dir string, readOnly bool, maxUploadSize int64) http.Handler {
+	if maxUploadSize <= 0 {
+		maxUploadSize = DefaultMaxUploadSize
+	}
+
+	files := http.FileServer(http.Dir(dir))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rel, ok := entryPath(r.URL.Path)
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			files.ServeHTTP(w, r)
+
+		case http.MethodPut:
+			if readOnly {
+				http.Error(w, "cache is read-only", http.StatusForbidden)
+				return
+			}
+			err := storeUpload(dir, rel, http.MaxBytesReader(w, r.Body, maxUploadSize))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, fmt.Sprintf("upload exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// entryPath validates a request path of the form /<key[:2]>/<key>.tar.gz or
+// /<key[:2]>/<key>.yml and returns it as a relative file path.
+func entryPath(urlPath string) (string, bool) {
+	clean := strings.TrimPrefix(path.Clean(urlPath), "/")
+	parts := strings.Split(clean, "/")
+	if len(parts) != 2 {
+		return "", false
+	}
+
+	name := parts[1]
+	key, ok := strings.CutSuffix(name, ".tar.gz")
+	if !ok {
+		key, ok = strings.CutSuffix(name, ".yml")
+	}
+	if !ok || validKey(key) != nil || key[:2] != parts[0] {
+		return "", false
+	}
+	return filepath.Join(parts[0], name), true
+}
+
+func storeUpload(dir string, rel string, body io.Reader) error {
+	target := filepath.Join(dir, rel)
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, body)
+	closeErr := tmp.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	if strings.HasSuffix(rel, ".yml") {
+		data, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return err
+		}
+		entry := &Entry{}
+		err = yaml.Unmarshal(data, entry)
+		if err != nil {
+			return fmt.Errorf("invalid cache entry: %w", err)
+		}
+		archive := strings.TrimSuffix(target, ".yml") + ".tar.gz"
+		err = VerifyFile(archive, entry.Checksum)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmp.Name(), target)
+}
@@ -1,7 +1,12 @@
 package ccommon
 
 import (
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -23,3 +28,115 @@ func GCCIsRealGCC(path string) (bool, error) {
 
 	return false, nil
 }
+
+// DetectedCompiler is a pair of C and C++ compilers found in a search directory.
+type DetectedCompiler struct {
+	/// The toolchain name, e.g. "system-gcc", "gcc-13" or "aarch64-linux-gnu-gcc".
+	Name        string
+	Family      string
+	Triple      string
+	Version     string
+	CCompiler   string
+	CXXCompiler string
+}
+
+// compilerRE matches C compiler names with an optional target triple prefix
+// and version suffix, e.g. gcc, clang-18 or aarch64-linux-gnu-gcc-13.
+var compilerRE = regexp.MustCompile(`^(?:([a-z0-9_.]+(?:-[a-z0-9_.]+)+)-)?(gcc|clang)(?:-(\d+(?:\.\d+)*))?$`)
+
+// FindCompilers scans the directories for versioned and triple-prefixed C
+// compilers that have a matching C++ compiler next to them. A name found in
+// several directories is taken from the first one. Compilers in PATH are
+// referred to by name, others by their full path.
+func FindCompilers(dirs []string) []DetectedCompiler {
+	seen := make(map[string]bool)
+	var compilers []DetectedCompiler
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			m := compilerRE.FindStringSubmatch(entry.Name())
+			if m == nil || seen[entry.Name()] {
+				continue
+			}
+			triple, family, version := m[1], m[2], m[3]
+
+			cxxName := "clang++"
+			if family == "gcc" {
+				cxxName = "g++"
+			}
+			if triple != "" {
+				cxxName = triple + "-" + cxxName
+			}
+			if version != "" {
+				cxxName = cxxName + "-" + version
+			}
+
+			cPath := filepath.Join(dir, entry.Name())
+			cxxPath := filepath.Join(dir, cxxName)
+			if !isExecutableFile(cPath) || !isExecutableFile(cxxPath) {
+				continue
+			}
+			seen[entry.Name()] = true
+
+			name := entry.Name()
+			if triple == "" && version == "" {
+				name = "system-" + family
+			}
+
+			compilers = append(compilers, DetectedCompiler{
+				Name:        name,
+				Family:      family,
+				Triple:      triple,
+				Version:     version,
+				CCompiler:   compilerCommand(cPath),
+				CXXCompiler: compilerCommand(cxxPath),
+			})
+		}
+	}
+
+	// Unversioned system compilers first, then by name.
+	sort.SliceStable(compilers, func(i, j int) bool {
+		si := strings.HasPrefix(compilers[i].Name, "system-")
+		sj := strings.HasPrefix(compilers[j].Name, "system-")
+		if si != sj {
+			return si
+		}
+		return compilers[i].Name < compilers[j].Name
+	})
+	return compilers
+}
+
+// CompilerSearchDirs returns the extra directories followed by the PATH entries.
+func CompilerSearchDirs(extra []string) []string {
+	dirs := append([]string{}, extra...)
+	return append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+}
+
+// DumpMachine returns the default target triple of a GCC or Clang compiler.
+func DumpMachine(compiler string) (string, error) {
+	out, err := exec.Command(compiler, "-dumpmachine").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s -dumpmachine: %w", compiler, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func isExecutableFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && info.Mode()&0111 != 0
+}
+
+// compilerCommand returns the name of the compiler if it is the one found in
+// PATH, and its full path otherwise.
+func compilerCommand(path string) string {
+	name := filepath.Base(path)
+	found, err := exec.LookPath(name)
+	if err == nil && found == path {
+		return name
+	}
+	return path
+}
@@ -0,0 +1,95 @@
+package ccommon
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTestExecutable writes an executable stub, or a plain file if exec is
+// false.
+func writeTestExecutable(t *testing.T, dir string, name string, exec bool) string {
+	t.Helper()
+	mode := os.FileMode(0644)
+	if exec {
+		mode = 0755
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindCompilers(t *testing.T) {
+	// Keep the stubs out of PATH so that compilers are referred to by path.
+	t.Setenv("PATH", "")
+
+	first := t.TempDir()
+	second := t.TempDir()
+
+	for _, name := range []string{
+		"gcc", "g++",
+		"gcc-13", "g++-13",
+		"aarch64-linux-gnu-gcc", "aarch64-linux-gnu-g++",
+		"clang-18", "clang++-18",
+		"arm-linux-gnueabihf-gcc-12", "arm-linux-gnueabihf-g++-12",
+		// No C++ compiler next to them.
+		"gcc-12", "clang",
+		// Not compilers.
+		"gcc-ar", "gcc-nm-13", "x86_64-linux-gnu-gcc-ar",
+	} {
+		writeTestExecutable(t, first, name, true)
+	}
+	// The C++ compiler is not executable.
+	writeTestExecutable(t, first, "gcc-11", true)
+	writeTestExecutable(t, first, "g++-11", false)
+	if err := os.Mkdir(filepath.Join(first, "clang-17"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestExecutable(t, first, "clang++-17", true)
+
+	// Names already found in the first directory are skipped, others are
+	// added from the second.
+	writeTestExecutable(t, second, "gcc-13", true)
+	writeTestExecutable(t, second, "g++-13", true)
+	writeTestExecutable(t, second, "clang", true)
+	writeTestExecutable(t, second, "clang++", true)
+
+	got := FindCompilers([]string{first, filepath.Join(first, "missing"), second})
+
+	in := func(dir string, name string) string { return filepath.Join(dir, name) }
+	want := []DetectedCompiler{
+		{Name: "system-clang", Family: "clang", CCompiler: in(second, "clang"), CXXCompiler: in(second, "clang++")},
+		{Name: "system-gcc", Family: "gcc", CCompiler: in(first, "gcc"), CXXCompiler: in(first, "g++")},
+		{Name: "aarch64-linux-gnu-gcc", Family: "gcc", Triple: "aarch64-linux-gnu", CCompiler: in(first, "aarch64-linux-gnu-gcc"), CXXCompiler: in(first, "aarch64-linux-gnu-g++")},
+		{Name: "arm-linux-gnueabihf-gcc-12", Family: "gcc", Triple: "arm-linux-gnueabihf", Version: "12", CCompiler: in(first, "arm-linux-gnueabihf-gcc-12"), CXXCompiler: in(first, "arm-linux-gnueabihf-g++-12")},
+		{Name: "clang-18", Family: "clang", Version: "18", CCompiler: in(first, "clang-18"), CXXCompiler: in(first, "clang++-18")},
+		{Name: "gcc-13", Family: "gcc", Version: "13", CCompiler: in(first, "gcc-13"), CXXCompiler: in(first, "g++-13")},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindCompilers() =")
+		for _, c := range got {
+			t.Errorf("  %+v", c)
+		}
+		t.Errorf("want")
+		for _, c := range want {
+			t.Errorf("  %+v", c)
+		}
+	}
+}
+
+func TestFindCompilersInPath(t *testing.T) {
+	dir := t.TempDir()
+	writeTestExecutable(t, dir, "gcc-13", true)
+	writeTestExecutable(t, dir, "g++-13", true)
+	t.Setenv("PATH", dir)
+
+	got := FindCompilers(CompilerSearchDirs(nil))
+	want := []DetectedCompiler{{Name: "gcc-13", Family: "gcc", Version: "13", CCompiler: "gcc-13", CXXCompiler: "g++-13"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindCompilers(PATH) = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,111 @@
+package ccommon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+type BuildStatus string
+
+const (
+	BuildStatusPending   BuildStatus = "pending"
+	BuildStatusSucceeded BuildStatus = "succeeded"
+	BuildStatusFailed    BuildStatus = "failed"
+)
+
+// BuildState records the outcome of every target in the most recent build,
+// keyed by "<toolchain>/<config>" (or "<toolchain>/<config>@<variant>") and
+// then by target name.
+type BuildState struct {
+	Results map[string]map[string]BuildStatus `yaml:"results"`
+}
+
+func buildStateKey(bp TargetBuildParameters) string {
+	return bp.Toolchain + "/" + bp.ConfigDirName()
+}
+
+func (s *BuildState) Status(bp TargetBuildParameters, targetName string) BuildStatus {
+	results, ok := s.Results[buildStateKey(bp)]
+	if !ok {
+		return BuildStatusPending
+	}
+	status, ok := results[targetName]
+	if !ok {
+		return BuildStatusPending
+	}
+	return status
+}
+
+func (s *BuildState) SetStatus(bp TargetBuildParameters, targetName string, status BuildStatus) {
+	if s.Results == nil {
+		s.Results = make(map[string]map[string]BuildStatus)
+	}
+	key := buildStateKey(bp)
+	if s.Results[key] == nil {
+		s.Results[key] = make(map[string]BuildStatus)
+	}
+	s.Results[key][targetName] = status
+}
+
+func (w *WorkspaceContext) BuildStatePath() string {
+	return filepath.Join(w.WorkspacePath, "buildspaces", "cbuild_state.yml")
+}
+
+// LoadBuildState loads the results of the previous build into w.State. A
+// missing state file is not an error, it just means nothing was built yet.
+func (w *WorkspaceContext) LoadBuildState(ctx context.Context) error {
+	w.State = &BuildState{}
+
+	data, err := os.ReadFile(w.BuildStatePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read build state: %w", err)
+	}
+
+	err = yaml.Unmarshal(data, w.State)
+	if err != nil {
+		return fmt.Errorf("failed to parse build state: %w", err)
+	}
+
+	return nil
+}
+
+func (w *WorkspaceContext) SaveBuildState(ctx context.Context) error {
+	if w.State == nil {
+		return nil
+	}
+
+	data, err := yaml.Marshal(w.State)
+	if err != nil {
+		return fmt.Errorf("failed to marshal build state: %w", err)
+	}
+
+	statePath := w.BuildStatePath()
+	err = os.MkdirAll(filepath.Dir(statePath), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create build state directory: %w", err)
+	}
+
+	err = os.WriteFile(statePath, data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write build state: %w", err)
+	}
+
+	return nil
+}
+
+// recordStatus updates the build state for a target and persists it, so that
+// an interrupted build can still be resumed.
+func (w *WorkspaceContext) recordStatus(ctx context.Context, bp TargetBuildParameters, targetName string, status BuildStatus) error {
+	if w.State == nil || bp.DryRun {
+		return nil
+	}
+	w.State.SetStatus(bp, targetName, status)
+	return w.SaveBuildState(ctx)
+}
@@ -0,0 +1,77 @@
+package ccommon
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSkipTarget(t *testing.T) {
+	bp := TargetBuildParameters{Toolchain: "gcc", BuildType: "Debug"}
+
+	tests := []struct {
+		name       string
+		status     BuildStatus
+		resume     bool
+		onlyFailed bool
+		depRebuilt bool
+		want       bool
+	}{
+		{name: "no flags", status: BuildStatusSucceeded, want: false},
+		{name: "resume succeeded", status: BuildStatusSucceeded, resume: true, want: true},
+		{name: "resume failed", status: BuildStatusFailed, resume: true, want: false},
+		{name: "resume pending", status: BuildStatusPending, resume: true, want: false},
+		{name: "resume dependency rebuilt", status: BuildStatusSucceeded, resume: true, depRebuilt: true, want: false},
+		{name: "only failed succeeded", status: BuildStatusSucceeded, onlyFailed: true, want: true},
+		{name: "only failed pending", status: BuildStatusPending, onlyFailed: true, want: true},
+		{name: "only failed failed", status: BuildStatusFailed, onlyFailed: true, want: false},
+		{name: "only failed dependency rebuilt", status: BuildStatusSucceeded, onlyFailed: true, depRebuilt: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &WorkspaceContext{
+				Config: WorkspaceConfig{
+					Targets: map[string]*TargetConfiguration{
+						"lib": {},
+						"app": {Depends: []string{"lib/sub"}},
+					},
+				},
+				State: &BuildState{},
+			}
+			w.State.SetStatus(bp, "app", tt.status)
+
+			bp := bp
+			bp.Resume = tt.resume
+			bp.OnlyFailed = tt.onlyFailed
+
+			rebuilt := map[string]bool{"lib": tt.depRebuilt}
+			mod, err := w.GetTarget(context.Background(), "app")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := w.skipTarget(context.Background(), mod, rebuilt, bp)
+			if err != nil {
+				t.Fatalf("skipTarget() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("skipTarget() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipTargetWithoutState(t *testing.T) {
+	w := &WorkspaceContext{
+		Config: WorkspaceConfig{Targets: map[string]*TargetConfiguration{"app": {}}},
+	}
+	mod, err := w.GetTarget(context.Background(), "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := w.skipTarget(context.Background(), mod, nil, TargetBuildParameters{Resume: true})
+	if err != nil || got {
+		t.Errorf("skipTarget() without state = %v, %v, want false, nil", got, err)
+	}
+}
@@ -0,0 +1,382 @@
+package ccommon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/cache"
+)
+
+type CacheConfig struct {
+	/// Enables the local cache of staged target installs. Unset in every
+	/// layer, the cache is enabled only if a remote cache is configured.
+	Enabled *bool `yaml:"enabled,omitempty"`
+
+	/// The cache directory, defaults to ~/.cache/cbuild.
+	Dir string `yaml:"dir,omitempty"`
+
+	/// A shared cache to fetch from, and optionally upload to. Using a remote
+	/// cache implies the local cache is enabled, unless enabled is set.
+	Remote *RemoteCacheConfig `yaml:"remote,omitempty"`
+}
+
+const (
+	RemoteCacheReadOnly  = "read-only"
+	RemoteCacheReadWrite = "read-write"
+)
+
+type RemoteCacheConfig struct {
+	/// Base URL of the remote cache, e.g. http://cache.example.com:8080
+	URL string `yaml:"url"`
+
+	/// Either "read-only" (the default) or "read-write".
+	Mode string `yaml:"mode,omitempty"`
+}
+
+// IsEnabled reports whether the cache is enabled.
+func (c CacheConfig) IsEnabled() bool {
+	return c.Enabled != nil && *c.Enabled
+}
+
+// EffectiveCacheConfig merges the workspace cache settings over the user's,
+// the workspace settings taking precedence.
+func (w *WorkspaceContext) EffectiveCacheConfig() CacheConfig {
+	var merged CacheConfig
+	var remote RemoteCacheConfig
+
+	layers := []*CacheConfig{}
+	if w.UserConfig != nil && w.UserConfig.Cache != nil {
+		layers = append(layers, w.UserConfig.Cache)
+	}
+	if w.Config.Cache != nil {
+		layers = append(layers, w.Config.Cache)
+	}
+
+	for _, layer := range layers {
+		if layer.Enabled != nil {
+			enabled := *layer.Enabled
+			merged.Enabled = &enabled
+		}
+		if layer.Dir != "" {
+			merged.Dir = layer.Dir
+		}
+		if layer.Remote != nil {
+			if layer.Remote.URL != "" {
+				remote.URL = layer.Remote.URL
+			}
+			if layer.Remote.Mode != "" {
+				remote.Mode = layer.Remote.Mode
+			}
+		}
+	}
+
+	if remote.URL != "" {
+		if remote.Mode == "" {
+			remote.Mode = RemoteCacheReadOnly
+		}
+		merged.Remote = &remote
+		if merged.Enabled == nil {
+			enabled := true
+			merged.Enabled = &enabled
+		}
+	}
+
+	return merged
+}
+
+// cacheKeyVersion is mixed into every cache key, bump it when the key inputs change.
+const cacheKeyVersion = "cbuild-staging-cache-v1"
+
+// workspacePlaceholder replaces the workspace path in cache key inputs, so
+// identical builds in different workspaces share cache entries.
+const workspacePlaceholder = "${CBUILD_WORKSPACE}"
+
+// CacheDir returns the configured cache directory, or the per-user default.
+func (w *WorkspaceContext) CacheDir() (string, error) {
+	cfg := w.EffectiveCacheConfig()
+	if cfg.Dir != "" {
+		dir := cfg.Dir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(w.WorkspacePath, dir)
+		}
+		return dir, nil
+	}
+	return cache.DefaultDir()
+}
+
+// StagingCache returns the local staging cache, or nil if caching is not
+// enabled for this workspace.
+func (w *WorkspaceContext) StagingCache() (*cache.Cache, error) {
+	if !w.EffectiveCacheConfig().IsEnabled() {
+		return nil, nil
+	}
+	dir, err := w.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return cache.New(dir), nil
+}
+
+// RemoteCache returns the configured remote cache and whether it may be
+// uploaded to, or nil if no remote cache is configured.
+func (w *WorkspaceContext) RemoteCache() (*cache.Remote, bool, error) {
+	cfg := w.EffectiveCacheConfig()
+	if cfg.Remote == nil {
+		return nil, false, nil
+	}
+
+	switch cfg.Remote.Mode {
+	case RemoteCacheReadOnly:
+		return cache.NewRemote(cfg.Remote.URL), false, nil
+	case RemoteCacheReadWrite:
+		return cache.NewRemote(cfg.Remote.URL), true, nil
+	}
+	return nil, false, fmt.Errorf("invalid remote cache mode %q, expected %s or %s", cfg.Remote.Mode, RemoteCacheReadOnly, RemoteCacheReadWrite)
+}
+
+func (w *WorkspaceContext) absWorkspacePath() (string, error) {
+	return filepath.Abs(w.WorkspacePath)
+}
+
+// SourceFingerprint identifies the contents of a target's source tree. Clean git
+// checkouts are identified by their revision, anything else is hashed.
+func (w *WorkspaceContext) SourceFingerprint(ctx context.Context, t *TargetContext) (string, error) {
+	src, err := t.CMakeSourcePath(ctx, w)
+	if err != nil {
+		return "", err
+	}
+
+	revCmd := exec.CommandContext(ctx, "git", "-C", src, "rev-parse", "HEAD")
+	rev, revErr := revCmd.Output()
+	if revErr == nil {
+		statusCmd := exec.CommandContext(ctx, "git", "-C", src, "status", "--porcelain", "--", ".")
+		status, statusErr := statusCmd.Output()
+		if statusErr == nil && len(strings.TrimSpace(string(status))) == 0 {
+			prefixCmd := exec.CommandContext(ctx, "git", "-C", src, "rev-parse", "--show-prefix")
+			prefix, err := prefixCmd.Output()
+			if err == nil {
+				return fmt.Sprintf("git:%s:%s", strings.TrimSpace(string(rev)), strings.TrimSpace(string(prefix))), nil
+			}
+		}
+	}
+
+	hash, err := cache.HashDirectory(src, ".git")
+	if err != nil {
+		return "", fmt.Errorf("failed to hash source tree %s: %w", src, err)
+	}
+	return "tree:" + hash, nil
+}
+
+// TargetCacheKey computes the cache key of a target from its source, configure
+// arguments, toolchain file, compilers and the cache keys of its dependencies. Keys are
+// memoized in keys.
+func (w *WorkspaceContext) TargetCacheKey(ctx context.Context, targetName string, bp TargetBuildParameters, keys map[string]string) (string, error) {
+	if key, ok := keys[targetName]; ok {
+		return key, nil
+	}
+
+	t, err := w.GetTarget(ctx, targetName)
+	if err != nil {
+		return "", err
+	}
+
+	absWorkspace, err := w.absWorkspacePath()
+	if err != nil {
+		return "", err
+	}
+	normalize := func(s string) string {
+		return strings.ReplaceAll(s, absWorkspace, workspacePlaceholder)
+	}
+
+	parts := []string{cacheKeyVersion}
+
+	fingerprint, err := w.SourceFingerprint(ctx, t)
+	if err != nil {
+		return "", err
+	}
+	parts = append(parts, "source "+fingerprint)
+
+	args, err := t.CMakeConfigureArgs(ctx, w, bp)
+	if err != nil {
+		return "", fmt.Errorf("failed to get cmake configure args: %w", err)
+	}
+	for _, arg := range args {
+		parts = append(parts, "arg "+normalize(arg))
+	}
+
+	toolchainFile, err := w.ToolchainFilePath(ctx, &t.Config, bp)
+	if err != nil {
+		return "", err
+	}
+	if toolchainFile != "" {
+		data, err := os.ReadFile(toolchainFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read toolchain file: %w", err)
+		}
+		parts = append(parts, "toolchain "+normalize(string(data)))
+	}
+
+	tc, _, err := w.LoadToolchain(ctx, bp.Toolchain)
+	if err != nil {
+		return "", fmt.Errorf("failed to load toolchain: %w", err)
+	}
+	if tcf, ok := tc.CMakeToolchain[HostToolchainKey()]; ok && tcf.Generate != nil {
+		compilers, err := w.compilerFingerprint(tcf.Generate)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, compilers...)
+	}
+
+	deps, err := w.TargetDependencies(ctx, targetName)
+	if err != nil {
+		return "", err
+	}
+	for _, dep := range deps {
+		depKey, err := w.TargetCacheKey(ctx, dep, bp, keys)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, fmt.Sprintf("dep %s %s", dep, depKey))
+	}
+
+	key := cache.HashStrings(parts...)
+	keys[targetName] = key
+	return key, nil
+}
+
+// restoreFromCache restores the staging directory of a staged target from the
+// cache. It returns true if the target was restored and does not need to be built.
+func (w *WorkspaceContext) restoreFromCache(ctx context.Context, c *cache.Cache, t *TargetContext, bp TargetBuildParameters, keys map[string]string) (bool, error) {
+	if c == nil || t.Config.Staged == nil || !*t.Config.Staged {
+		return false, nil
+	}
+
+	key, err := w.TargetCacheKey(ctx, t.Name, bp, keys)
+	if err != nil {
+		return false, fmt.Errorf("failed to compute cache key: %w", err)
+	}
+
+	entry, err := c.Lookup(key)
+	if err != nil {
+		return false, err
+	}
+	if entry == nil && !bp.DryRun {
+		entry, err = w.fetchFromRemote(ctx, c, t, key)
+		if err != nil {
+			return false, err
+		}
+	}
+	if entry == nil {
+		if !bp.DryRun {
+			if err := c.RecordMiss(); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}
+		return false, nil
+	}
+
+	stagingPath, err := t.CMakeStagingPath(ctx, w, bp)
+	if err != nil {
+		return false, fmt.Errorf("failed to get staging path: %w", err)
+	}
+	stagingPath, err = filepath.Abs(stagingPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to get absolute staging path: %w", err)
+	}
+
+	if bp.DryRun {
+		fmt.Printf("dry-run: would restore %s from cache entry %s\n", t.Name, key)
+		return true, nil
+	}
+
+	absWorkspace, err := w.absWorkspacePath()
+	if err != nil {
+		return false, err
+	}
+
+	err = c.Restore(key, stagingPath, absWorkspace)
+	if err != nil {
+		fmt.Printf("Warning: failed to restore %s from cache, building instead: %v\n", t.Name, err)
+		return false, nil
+	}
+	if err := c.RecordHit(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	fmt.Printf("Restored %s from cache\n", t.Name)
+	return true, nil
+}
+
+// storeInCache adds the staging directory of a freshly built staged target to the cache.
+func (w *WorkspaceContext) storeInCache(ctx context.Context, c *cache.Cache, t *TargetContext, bp TargetBuildParameters, keys map[string]string) error {
+	if c == nil || bp.DryRun || t.Config.Staged == nil || !*t.Config.Staged {
+		return nil
+	}
+
+	key, err := w.TargetCacheKey(ctx, t.Name, bp, keys)
+	if err != nil {
+		return fmt.Errorf("failed to compute cache key: %w", err)
+	}
+
+	stagingPath, err := t.CMakeStagingPath(ctx, w, bp)
+	if err != nil {
+		return fmt.Errorf("failed to get staging path: %w", err)
+	}
+
+	absWorkspace, err := w.absWorkspacePath()
+	if err != nil {
+		return err
+	}
+
+	err = c.Store(key, stagingPath, cache.Entry{
+		Target: t.Name,
+		Prefix: absWorkspace,
+	})
+	if err != nil {
+		return err
+	}
+
+	remote, writable, err := w.RemoteCache()
+	if err != nil {
+		return err
+	}
+	if remote == nil || !writable {
+		return nil
+	}
+
+	err = remote.Upload(ctx, key, c)
+	if err != nil {
+		return fmt.Errorf("failed to upload to remote cache: %w", err)
+	}
+	fmt.Printf("Uploaded %s to remote cache\n", t.Name)
+	return nil
+}
+
+// fetchFromRemote downloads a missing entry from the remote cache into the
+// local cache. Remote failures are reported but do not fail the build.
+func (w *WorkspaceContext) fetchFromRemote(ctx context.Context, c *cache.Cache, t *TargetContext, key string) (*cache.Entry, error) {
+	remote, _, err := w.RemoteCache()
+	if err != nil {
+		return nil, err
+	}
+	if remote == nil {
+		return nil, nil
+	}
+
+	found, err := remote.Fetch(ctx, key, c)
+	if err != nil {
+		fmt.Printf("Warning: failed to fetch %s from remote cache: %v\n", t.Name, err)
+		return nil, nil
+	}
+	if !found {
+		return nil, nil
+	}
+
+	fmt.Printf("Downloaded %s from remote cache\n", t.Name)
+	return c.Lookup(key)
+}
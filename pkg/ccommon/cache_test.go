@@ -0,0 +1,34 @@
+package ccommon
+
+import "testing"
+
+func TestEffectiveCacheConfig(t *testing.T) {
+	on, off := true, false
+
+	tests := []struct {
+		name      string
+		user      *CacheConfig
+		workspace *CacheConfig
+		want      bool
+	}{
+		{name: "unset", want: false},
+		{name: "user enabled", user: &CacheConfig{Enabled: &on}, want: true},
+		{name: "workspace disables user cache", user: &CacheConfig{Enabled: &on}, workspace: &CacheConfig{Enabled: &off}, want: false},
+		{name: "workspace enables", user: &CacheConfig{Enabled: &off}, workspace: &CacheConfig{Enabled: &on}, want: true},
+		{name: "workspace leaves user setting", user: &CacheConfig{Enabled: &on}, workspace: &CacheConfig{Dir: "cache"}, want: true},
+		{name: "remote implies enabled", user: &CacheConfig{Remote: &RemoteCacheConfig{URL: "http://cache"}}, want: true},
+		{name: "remote with explicit disable", user: &CacheConfig{Remote: &RemoteCacheConfig{URL: "http://cache"}}, workspace: &CacheConfig{Enabled: &off}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &WorkspaceContext{
+				Config:     WorkspaceConfig{Cache: tt.workspace},
+				UserConfig: &UserConfig{Cache: tt.user},
+			}
+			if got := w.EffectiveCacheConfig().IsEnabled(); got != tt.want {
+				t.Errorf("EffectiveCacheConfig().IsEnabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
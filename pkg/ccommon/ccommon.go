@@ -15,19 +15,55 @@ type CMakeGenerateToolchainFileOptions struct {
 	CCompiler          string             `yaml:"c_compiler"`
 	CXXCompiler        string             `yaml:"cxx_compiler"`
 	Linker             string             `yaml:"linker,omitempty"`
+	LinkerType         cmake.LinkerType   `yaml:"linker_type,omitempty"`
 	ExtraCompilerFlags []string           `yaml:"extra_compiler_flags,omitempty"`
 	ExtraCXXFlags      []string           `yaml:"extra_cxx_flags,omitempty"`
 	ExtraCFlags        []string           `yaml:"extra_c_flags,omitempty"`
+
+	ExtraLinkerFlags       []string `yaml:"extra_linker_flags,omitempty"`
+	ExtraSharedLinkerFlags []string `yaml:"extra_shared_linker_flags,omitempty"`
+	ExtraExeLinkerFlags    []string `yaml:"extra_exe_linker_flags,omitempty"`
+
+	/// Cross compilation settings. Relative paths are relative to the workspace.
+	Sysroot           string                   `yaml:"sysroot,omitempty"`
+	TargetTriple      string                   `yaml:"target_triple,omitempty"`
+	FindRootPath      []string                 `yaml:"find_root_path,omitempty"`
+	FindRootPathModes *cmake.FindRootPathModes `yaml:"find_root_path_mode,omitempty"`
+
+	/// The compilers the toolchain was created with, checked before building.
+	CCompilerIdentity   *CompilerIdentity `yaml:"c_compiler_identity,omitempty"`
+	CXXCompilerIdentity *CompilerIdentity `yaml:"cxx_compiler_identity,omitempty"`
 }
 
 type Toolchain struct {
+	/// The name of a toolchain this toolchain extends. Its settings are merged
+	/// under this toolchain's, with list fields appended.
+	Extends string `yaml:"extends,omitempty"`
+
 	CMakeToolchain map[string]CMakeToolchainOptions `yaml:"cmake_toolchain"`
 	TargetArch     system.Processor                 `yaml:"target_arch"`
 	TargetSystem   system.Platform                  `yaml:"target_system"`
+
+	/// Configuration definitions for this toolchain, taking precedence over
+	/// the workspace definitions of the same name.
+	Configurations map[string]*cmake.ConfigurationDefinition `yaml:"configurations,omitempty"`
+
+	/// A command the compilers are run through, such as ccache or sccache,
+	/// taking precedence over the workspace compiler_launcher. "none"
+	/// disables the workspace launcher for this toolchain.
+	CompilerLauncher string `yaml:"compiler_launcher,omitempty"`
 }
 
 type TargetBuildParameters struct {
 	Toolchain string
 	BuildType string
 	DryRun    bool
+
+	// Variant is the name of a workspace variant, or empty for none.
+	Variant string
+
+	// Resume skips targets that succeeded in the previous build.
+	Resume bool
+	// OnlyFailed rebuilds only targets that failed in the previous build, plus their dependents.
+	OnlyFailed bool
 }
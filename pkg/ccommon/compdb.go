@@ -0,0 +1,127 @@
+package ccommon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MergeCompileCommands merges the compile_commands.json of each target's build
+// tree into <workspace>/compile_commands.json, returning the number of
+// commands written. If link is set, the root of each target's source also gets
+// a compile_commands.json symlink to the target's build tree database. When
+// several targets share a source, the first of them gets the link.
+func (w *WorkspaceContext) MergeCompileCommands(ctx context.Context, targets []string, bp TargetBuildParameters, link bool) (int, error) {
+	var merged []json.RawMessage
+	linked := make(map[string]string)
+
+	for _, name := range targets {
+		t, err := w.GetTarget(ctx, name)
+		if err != nil {
+			return 0, err
+		}
+
+		buildPath, err := t.CMakeBuildPath(ctx, w, bp)
+		if err != nil {
+			return 0, err
+		}
+		buildPath, err = filepath.Abs(buildPath)
+		if err != nil {
+			return 0, err
+		}
+
+		dbPath := filepath.Join(buildPath, "compile_commands.json")
+		data, err := os.ReadFile(dbPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				fmt.Printf("Warning: %s has no compile_commands.json in %s, skipping\n", name, buildPath)
+				continue
+			}
+			return 0, fmt.Errorf("failed to read %s: %w", dbPath, err)
+		}
+
+		var commands []json.RawMessage
+		err = json.Unmarshal(data, &commands)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %s: %w", dbPath, err)
+		}
+		merged = append(merged, commands...)
+
+		if link {
+			srcRoot := t.sourceRootPath(w)
+			if other, ok := linked[srcRoot]; ok {
+				fmt.Printf("Warning: %s shares its source with %s, not linking %s into %s\n", name, other, dbPath, srcRoot)
+				continue
+			}
+			linked[srcRoot] = name
+
+			err = linkCompileCommands(srcRoot, dbPath, bp.DryRun)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if merged == nil {
+		merged = []json.RawMessage{}
+	}
+
+	outPath := filepath.Join(w.WorkspacePath, "compile_commands.json")
+	if bp.DryRun {
+		fmt.Printf("dry-run: would write %d compile commands to %s\n", len(merged), outPath)
+		return len(merged), nil
+	}
+
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal compile commands: %w", err)
+	}
+	err = os.WriteFile(outPath, append(data, '\n'), 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", outPath, err)
+	}
+
+	return len(merged), nil
+}
+
+// sourceRootPath returns the root of the source a target is built from,
+// ignoring its root_path.
+func (t *TargetContext) sourceRootPath(w *WorkspaceContext) string {
+	name := t.TargetSourceName()
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(w.WorkspacePath, "sources", name)
+}
+
+// linkCompileCommands points srcRoot/compile_commands.json at dbPath, replacing
+// an existing symlink but not a regular file.
+func linkCompileCommands(srcRoot string, dbPath string, dryRun bool) error {
+	linkPath := filepath.Join(srcRoot, "compile_commands.json")
+
+	if info, err := os.Lstat(linkPath); err == nil {
+		if info.Mode()&os.ModeSymlink == 0 {
+			fmt.Printf("Warning: %s exists and is not a symlink, not replacing it\n", linkPath)
+			return nil
+		}
+		if !dryRun {
+			err = os.Remove(linkPath)
+			if err != nil {
+				return fmt.Errorf("failed to remove %s: %w", linkPath, err)
+			}
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("dry-run: would link %s -> %s\n", linkPath, dbPath)
+		return nil
+	}
+
+	err := os.Symlink(dbPath, linkPath)
+	if err != nil {
+		return fmt.Errorf("failed to link %s: %w", linkPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package ccommon
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func compdbWorkspace(t *testing.T) (*WorkspaceContext, TargetBuildParameters) {
+	ext := "external"
+	w := &WorkspaceContext{
+		WorkspacePath: t.TempDir(),
+		Config: WorkspaceConfig{
+			Targets: map[string]*TargetConfiguration{
+				"a":       {Source: "shared"},
+				"b":       {Source: "shared", RootPath: "sub"},
+				"c":       {ExternalSourceOverride: &ext},
+				"missing": {},
+			},
+		},
+	}
+	bp := TargetBuildParameters{Toolchain: "tc", BuildType: "Debug"}
+	for _, name := range []string{"a", "b", "c"} {
+		writeTestFile(t, compdbPath(w, name), `[{"file": "`+name+`.c"}]`)
+	}
+	return w, bp
+}
+
+func compdbPath(w *WorkspaceContext, name string) string {
+	return filepath.Join(w.WorkspacePath, "buildspaces", "tc", name, "Debug", "compile_commands.json")
+}
+
+func TestMergeCompileCommands(t *testing.T) {
+	w, bp := compdbWorkspace(t)
+
+	count, err := w.MergeCompileCommands(context.Background(), []string{"a", "b", "c", "missing"}, bp, false)
+	if err != nil {
+		t.Fatalf("MergeCompileCommands() error = %v", err)
+	}
+	if count != 3 {
+		t.Errorf("MergeCompileCommands() = %d, want 3", count)
+	}
+
+	data, err := os.ReadFile(filepath.Join(w.WorkspacePath, "compile_commands.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var commands []map[string]string
+	if err := json.Unmarshal(data, &commands); err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]string{{"file": "a.c"}, {"file": "b.c"}, {"file": "c.c"}}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("merged compile_commands.json = %v, want %v", commands, want)
+	}
+
+	if _, err := os.Lstat(filepath.Join(w.WorkspacePath, "sources", "shared", "compile_commands.json")); !os.IsNotExist(err) {
+		t.Errorf("MergeCompileCommands without link created a link, Lstat error = %v", err)
+	}
+}
+
+func TestMergeCompileCommandsDryRun(t *testing.T) {
+	w, bp := compdbWorkspace(t)
+	bp.DryRun = true
+
+	count, err := w.MergeCompileCommands(context.Background(), []string{"a", "c"}, bp, true)
+	if err != nil {
+		t.Fatalf("MergeCompileCommands() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("MergeCompileCommands() = %d, want 2", count)
+	}
+	for _, path := range []string{
+		filepath.Join(w.WorkspacePath, "compile_commands.json"),
+		filepath.Join(w.WorkspacePath, "sources", "shared", "compile_commands.json"),
+	} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("dry run created %s", path)
+		}
+	}
+}
+
+func TestMergeCompileCommandsLink(t *testing.T) {
+	w, bp := compdbWorkspace(t)
+	shared := filepath.Join(w.WorkspacePath, "sources", "shared")
+	external := filepath.Join(w.WorkspacePath, "sources", "external")
+	for _, dir := range []string{filepath.Join(shared, "sub"), external} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A stale link is replaced, a regular file is kept.
+	if err := os.Symlink("stale", filepath.Join(shared, "compile_commands.json")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(external, "compile_commands.json"), "mine")
+
+	_, err := w.MergeCompileCommands(context.Background(), []string{"a", "b", "c"}, bp, true)
+	if err != nil {
+		t.Fatalf("MergeCompileCommands() error = %v", err)
+	}
+
+	// a and b share a source, so the link goes to its root and points at the
+	// first target's database.
+	got, err := os.Readlink(filepath.Join(shared, "compile_commands.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := compdbPath(w, "a"); got != want {
+		t.Errorf("sources/shared/compile_commands.json -> %s, want %s", got, want)
+	}
+	if _, err := os.Lstat(filepath.Join(shared, "sub", "compile_commands.json")); !os.IsNotExist(err) {
+		t.Errorf("link created under root_path, Lstat error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(external, "compile_commands.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "mine" {
+		t.Errorf("sources/external/compile_commands.json = %q, want it kept", data)
+	}
+
+	// Linking again replaces the link made by the previous run.
+	_, err = w.MergeCompileCommands(context.Background(), []string{"b"}, bp, true)
+	if err != nil {
+		t.Fatalf("MergeCompileCommands() error = %v", err)
+	}
+	got, err = os.Readlink(filepath.Join(shared, "compile_commands.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := compdbPath(w, "b"); got != want {
+		t.Errorf("sources/shared/compile_commands.json -> %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+package ccommon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	CompilerCheckWarn  = "warn"
+	CompilerCheckError = "error"
+	CompilerCheckOff   = "off"
+)
+
+// CompilerIdentity records the compiler binary a toolchain was created with,
+// so that a compiler changed by a system upgrade is noticed.
+type CompilerIdentity struct {
+	Path    string `yaml:"path"`
+	Version string `yaml:"version,omitempty"`
+	Triple  string `yaml:"triple,omitempty"`
+	SHA256  string `yaml:"sha256"`
+}
+
+// IdentifyCompiler resolves a compiler name or path and returns its identity.
+// The version is the first line of `--version` and the triple the output of
+// `-dumpmachine`, both are empty if the compiler does not support them.
+func IdentifyCompiler(compiler string) (*CompilerIdentity, error) {
+	path, err := exec.LookPath(compiler)
+	if err != nil {
+		return nil, fmt.Errorf("compiler %s not found: %w", compiler, err)
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve compiler %s: %w", compiler, err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open compiler %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash compiler %s: %w", path, err)
+	}
+
+	id := &CompilerIdentity{
+		Path:   path,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+	}
+
+	out, err := exec.Command(path, "--version").Output()
+	if err == nil {
+		id.Version = strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	}
+
+	triple, err := DumpMachine(path)
+	if err == nil {
+		id.Triple = triple
+	}
+
+	return id, nil
+}
+
+// Differences describes how the actual compiler differs from the recorded one.
+func (id *CompilerIdentity) Differences(actual *CompilerIdentity) []string {
+	var diffs []string
+	if id.Path != actual.Path {
+		diffs = append(diffs, fmt.Sprintf("path %s is now %s", id.Path, actual.Path))
+	}
+	if id.Version != actual.Version {
+		diffs = append(diffs, fmt.Sprintf("version %q is now %q", id.Version, actual.Version))
+	}
+	if id.Triple != actual.Triple {
+		diffs = append(diffs, fmt.Sprintf("target %s is now %s", id.Triple, actual.Triple))
+	}
+	if id.SHA256 != actual.SHA256 && len(diffs) == 0 {
+		diffs = append(diffs, "binary has changed")
+	}
+	return diffs
+}
+
+// identifyCompiler returns the identity of a compiler, memoized for the
+// lifetime of the workspace context.
+func (w *WorkspaceContext) identifyCompiler(compiler string) (*CompilerIdentity, error) {
+	if id, ok := w.compilerIdentities[compiler]; ok {
+		return id, nil
+	}
+	id, err := IdentifyCompiler(compiler)
+	if err != nil {
+		return nil, err
+	}
+	if w.compilerIdentities == nil {
+		w.compilerIdentities = make(map[string]*CompilerIdentity)
+	}
+	w.compilerIdentities[compiler] = id
+	return id, nil
+}
+
+// CheckCompilers compares the compilers recorded in a generated toolchain with
+// the ones now installed. Depending on the workspace compiler_check setting a
+// mismatch is printed as a warning, is an error, or is not checked.
+func (w *WorkspaceContext) CheckCompilers(ctx context.Context, toolchainName string, opts *CMakeGenerateToolchainFileOptions) error {
+	mode := w.Config.CompilerCheck
+	if mode == "" {
+		mode = CompilerCheckWarn
+	}
+	switch mode {
+	case CompilerCheckOff:
+		return nil
+	case CompilerCheckWarn, CompilerCheckError:
+	default:
+		return fmt.Errorf("invalid compiler_check %q, must be warn, error or off", mode)
+	}
+
+	for _, c := range []struct {
+		compiler string
+		recorded *CompilerIdentity
+	}{
+		{opts.CCompiler, opts.CCompilerIdentity},
+		{opts.CXXCompiler, opts.CXXCompilerIdentity},
+	} {
+		if c.compiler == "" || c.recorded == nil {
+			continue
+		}
+
+		actual, err := w.identifyCompiler(c.compiler)
+		if err != nil {
+			return err
+		}
+
+		diffs := c.recorded.Differences(actual)
+		if len(diffs) == 0 {
+			continue
+		}
+
+		msg := fmt.Sprintf("compiler %s of toolchain %s has changed since the toolchain was created: %s", c.compiler, toolchainName, strings.Join(diffs, ", "))
+		if mode == CompilerCheckError {
+			return errors.New(msg)
+		}
+		fmt.Printf("Warning: %s\n", msg)
+	}
+	return nil
+}
+
+// compilerFingerprint returns a description of the compilers of a generated
+// toolchain for configure fingerprints such as cache keys.
+func (w *WorkspaceContext) compilerFingerprint(opts *CMakeGenerateToolchainFileOptions) ([]string, error) {
+	var parts []string
+	for _, compiler := range []string{opts.CCompiler, opts.CXXCompiler} {
+		if compiler == "" {
+			continue
+		}
+		id, err := w.identifyCompiler(compiler)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, fmt.Sprintf("compiler %s %s %s", id.SHA256, id.Version, id.Triple))
+	}
+	return parts, nil
+}
@@ -0,0 +1,92 @@
+package ccommon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDifferences(t *testing.T) {
+	recorded := &CompilerIdentity{Path: "/usr/bin/gcc-13", Version: "gcc 13.2.0", Triple: "x86_64-linux-gnu", SHA256: "aaaa"}
+
+	tests := []struct {
+		name   string
+		actual CompilerIdentity
+		want   []string
+	}{
+		{"same", *recorded, nil},
+		{"path", CompilerIdentity{Path: "/opt/bin/gcc-13", Version: "gcc 13.2.0", Triple: "x86_64-linux-gnu", SHA256: "aaaa"}, []string{"path /usr/bin/gcc-13 is now /opt/bin/gcc-13"}},
+		{"version", CompilerIdentity{Path: "/usr/bin/gcc-13", Version: "gcc 13.3.0", Triple: "x86_64-linux-gnu", SHA256: "bbbb"}, []string{`version "gcc 13.2.0" is now "gcc 13.3.0"`}},
+		{"triple", CompilerIdentity{Path: "/usr/bin/gcc-13", Version: "gcc 13.2.0", Triple: "aarch64-linux-gnu", SHA256: "aaaa"}, []string{"target x86_64-linux-gnu is now aarch64-linux-gnu"}},
+		{"binary", CompilerIdentity{Path: "/usr/bin/gcc-13", Version: "gcc 13.2.0", Triple: "x86_64-linux-gnu", SHA256: "bbbb"}, []string{"binary has changed"}},
+		{"several", CompilerIdentity{Path: "/opt/bin/gcc-13", Version: "gcc 13.3.0", Triple: "x86_64-linux-gnu", SHA256: "bbbb"}, []string{"path /usr/bin/gcc-13 is now /opt/bin/gcc-13", `version "gcc 13.2.0" is now "gcc 13.3.0"`}},
+	}
+
+	for _, tt := range tests {
+		got := recorded.Differences(&tt.actual)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Differences() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// writeTestCompiler writes a compiler stub that answers --version and
+// -dumpmachine.
+func writeTestCompiler(t *testing.T, dir string, name string, version string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	script := "#!/bin/sh\ncase \"$1\" in\n--version) echo \"" + version + "\" ;;\n-dumpmachine) echo x86_64-linux-gnu ;;\nesac\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckCompilers(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cc := writeTestCompiler(t, dir, "cc", "stubcc 1.0")
+	cxx := writeTestCompiler(t, dir, "c++", "stubcc 1.0")
+
+	current, err := IdentifyCompiler(cc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Version != "stubcc 1.0" || current.Triple != "x86_64-linux-gnu" {
+		t.Fatalf("IdentifyCompiler(%s) = %+v, want version stubcc 1.0 and triple x86_64-linux-gnu", cc, current)
+	}
+	old := *current
+	old.Version = "stubcc 0.9"
+
+	unchanged := &CMakeGenerateToolchainFileOptions{CCompiler: cc, CCompilerIdentity: current}
+	changed := &CMakeGenerateToolchainFileOptions{CCompiler: cc, CXXCompiler: cxx, CCompilerIdentity: &old}
+	missing := &CMakeGenerateToolchainFileOptions{CCompiler: filepath.Join(dir, "missing"), CCompilerIdentity: current}
+	unrecorded := &CMakeGenerateToolchainFileOptions{CCompiler: filepath.Join(dir, "missing")}
+
+	tests := []struct {
+		mode    string
+		opts    *CMakeGenerateToolchainFileOptions
+		wantErr bool
+	}{
+		{"", unchanged, false},
+		{"", changed, false},
+		{CompilerCheckWarn, changed, false},
+		{CompilerCheckError, unchanged, false},
+		{CompilerCheckError, changed, true},
+		{CompilerCheckError, missing, true},
+		{CompilerCheckError, unrecorded, false},
+		{CompilerCheckOff, changed, false},
+		{CompilerCheckOff, missing, false},
+		{"sometimes", unchanged, true},
+	}
+
+	for _, tt := range tests {
+		w := &WorkspaceContext{Config: WorkspaceConfig{CompilerCheck: tt.mode}}
+		err := w.CheckCompilers(ctx, "stub", tt.opts)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckCompilers(mode %q, %s) error = %v, wantErr %v", tt.mode, tt.opts.CCompiler, err, tt.wantErr)
+		}
+	}
+}
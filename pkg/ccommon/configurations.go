@@ -0,0 +1,113 @@
+package ccommon
+
+import (
+	"fmt"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/cmake"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnmarshalYAML accepts `configurations:` either as a list of names or as a
+// mapping from names to configuration definitions.
+func (c *WorkspaceConfig) UnmarshalYAML(node *yaml.Node) error {
+	type Alias WorkspaceConfig
+
+	var definitions map[string]*cmake.ConfigurationDefinition
+	if node.Kind == yaml.MappingNode {
+		content := append([]*yaml.Node{}, node.Content...)
+		for i := 0; i+1 < len(content); i += 2 {
+			if content[i].Value != "configurations" || content[i+1].Kind != yaml.MappingNode {
+				continue
+			}
+
+			defs := content[i+1]
+			names := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			definitions = make(map[string]*cmake.ConfigurationDefinition)
+			for j := 0; j+1 < len(defs.Content); j += 2 {
+				name := defs.Content[j].Value
+				def := &cmake.ConfigurationDefinition{}
+				err := defs.Content[j+1].Decode(def)
+				if err != nil {
+					return fmt.Errorf("configuration %s: %w", name, err)
+				}
+				definitions[name] = def
+				names.Content = append(names.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name})
+			}
+			content[i+1] = names
+		}
+		node = &yaml.Node{Kind: node.Kind, Tag: node.Tag, Content: content}
+	}
+
+	err := node.Decode((*Alias)(c))
+	if err != nil {
+		return err
+	}
+	for _, name := range c.Configurations {
+		err = ValidateConfigurationName(name)
+		if err != nil {
+			return err
+		}
+	}
+	for name := range c.Variants {
+		err = ValidateVariantName(name)
+		if err != nil {
+			return err
+		}
+	}
+	c.ConfigurationDefinitions = definitions
+	return nil
+}
+
+// MarshalYAML writes `configurations:` as a mapping when any configuration
+// has a definition, and as a list of names otherwise.
+func (c WorkspaceConfig) MarshalYAML() (interface{}, error) {
+	type Alias WorkspaceConfig
+	node := &yaml.Node{}
+	err := node.Encode((Alias)(c))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.ConfigurationDefinitions) == 0 {
+		return node, nil
+	}
+
+	defs := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, name := range c.Configurations {
+		def := c.ConfigurationDefinitions[name]
+		if def == nil {
+			def = &cmake.ConfigurationDefinition{}
+		}
+		value := &yaml.Node{}
+		err = value.Encode(def)
+		if err != nil {
+			return nil, err
+		}
+		defs.Content = append(defs.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, value)
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "configurations" {
+			node.Content[i+1] = defs
+			break
+		}
+	}
+
+	return node, nil
+}
+
+// ConfigurationDefinitions returns the workspace configuration definitions
+// merged with those of the toolchain, which take precedence.
+func (w *WorkspaceContext) ConfigurationDefinitions(tc *Toolchain) map[string]*cmake.ConfigurationDefinition {
+	defs := make(map[string]*cmake.ConfigurationDefinition)
+	for name, def := range w.Config.ConfigurationDefinitions {
+		defs[name] = def
+	}
+	if tc != nil {
+		for name, def := range tc.Configurations {
+			defs[name] = def
+		}
+	}
+	return defs
+}
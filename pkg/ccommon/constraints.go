@@ -0,0 +1,166 @@
+package ccommon
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/host"
+	"gitlab.com/rpnx/cbuild-go/pkg/system"
+)
+
+// TargetSystemOrHost returns the platform the toolchain builds for. Toolchains
+// without a target_system build for the host.
+func (tc *Toolchain) TargetSystemOrHost() system.Platform {
+	if tc.TargetSystem == system.PlatformUnknown {
+		return host.DetectHostPlatform()
+	}
+	return tc.TargetSystem
+}
+
+// TargetArchOrHost returns the processor the toolchain builds for. Toolchains
+// without a target_arch build for the host.
+func (tc *Toolchain) TargetArchOrHost() system.Processor {
+	if tc.TargetArch == system.ProcessorUnknown {
+		return host.DetectHostProcessor()
+	}
+	return tc.TargetArch
+}
+
+// UnsupportedReason returns why a target cannot be built with a toolchain, or
+// an empty string if its platforms, arch and toolchains constraints allow it.
+func (t *TargetContext) UnsupportedReason(toolchainName string, tc *Toolchain) (string, error) {
+	if len(t.Config.Platforms) > 0 {
+		platform := tc.TargetSystemOrHost()
+		found := false
+		for _, p := range t.Config.Platforms {
+			if p == platform {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("platform %s is not one of %s", platform, joinStrings(t.Config.Platforms)), nil
+		}
+	}
+
+	if len(t.Config.Arch) > 0 {
+		arch := tc.TargetArchOrHost()
+		found := false
+		for _, a := range t.Config.Arch {
+			if a == arch {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("arch %s is not one of %s", arch, joinStrings(t.Config.Arch)), nil
+		}
+	}
+
+	if len(t.Config.Toolchains) > 0 {
+		found := false
+		for _, pattern := range t.Config.Toolchains {
+			ok, err := path.Match(pattern, toolchainName)
+			if err != nil {
+				return "", fmt.Errorf("invalid toolchain pattern %q for target %s: %w", pattern, t.Name, err)
+			}
+			if ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("toolchain %s is not one of %s", toolchainName, strings.Join(t.Config.Toolchains, ", ")), nil
+		}
+	}
+
+	return "", nil
+}
+
+func joinStrings[T fmt.Stringer](values []T) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = v.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// UnsupportedTargets returns the targets in order that cannot be built with
+// the toolchain, mapped to the reason. It is an error for a supported target
+// in order to depend on an unsupported one, directly or transitively, whether
+// or not the dependency is in order itself.
+func (w *WorkspaceContext) UnsupportedTargets(ctx context.Context, order []string, bp TargetBuildParameters) (map[string]string, error) {
+	tc, _, err := w.LoadToolchain(ctx, bp.Toolchain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load toolchain: %w", err)
+	}
+
+	reasons := make(map[string]string)
+	reasonFor := func(name string) (string, error) {
+		if reason, ok := reasons[name]; ok {
+			return reason, nil
+		}
+		t, err := w.GetTarget(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		reason, err := t.UnsupportedReason(bp.Toolchain, tc)
+		if err != nil {
+			return "", err
+		}
+		reasons[name] = reason
+		return reason, nil
+	}
+
+	unsupported := make(map[string]string)
+	for _, name := range order {
+		reason, err := reasonFor(name)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			unsupported[name] = reason
+		}
+	}
+
+	for _, name := range order {
+		if _, ok := unsupported[name]; ok {
+			continue
+		}
+		// The build order of a target holds its dependencies, transitively,
+		// followed by the target itself.
+		deps, err := w.TargetBuildOrder(ctx, []string{name})
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range deps {
+			if dep == name {
+				continue
+			}
+			reason, err := reasonFor(dep)
+			if err != nil {
+				return nil, err
+			}
+			if reason != "" {
+				return nil, fmt.Errorf("target %s depends on %s, which cannot be built with toolchain %s: %s", name, dep, bp.Toolchain, reason)
+			}
+		}
+	}
+
+	return unsupported, nil
+}
+
+// CheckTargetSupported returns an error if the target, or one of its
+// dependencies, cannot be built with the toolchain.
+func (w *WorkspaceContext) CheckTargetSupported(ctx context.Context, name string, bp TargetBuildParameters) error {
+	unsupported, err := w.UnsupportedTargets(ctx, []string{name}, bp)
+	if err != nil {
+		return err
+	}
+	if reason, ok := unsupported[name]; ok {
+		return fmt.Errorf("target %s cannot be built with toolchain %s: %s", name, bp.Toolchain, reason)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package ccommon
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/host"
+	"gitlab.com/rpnx/cbuild-go/pkg/system"
+	"gopkg.in/yaml.v3"
+)
+
+func TestUnsupportedReason(t *testing.T) {
+	native := &Toolchain{}
+	cross := &Toolchain{TargetSystem: system.PlatformLinux, TargetArch: crossArch()}
+
+	tests := []struct {
+		name   string
+		config TargetConfiguration
+		tcName string
+		tc     *Toolchain
+		want   string
+	}{
+		{"no constraints", TargetConfiguration{}, "gcc", native, ""},
+		{"host platform", TargetConfiguration{Platforms: []system.Platform{host.DetectHostPlatform()}}, "gcc", native, ""},
+		{"other platform", TargetConfiguration{Platforms: []system.Platform{system.PlatformFreeBSD}}, "gcc", cross, "platform " + system.PlatformLinux.String() + " is not one of " + system.PlatformFreeBSD.String()},
+		{"host arch", TargetConfiguration{Arch: []system.Processor{host.DetectHostProcessor()}}, "gcc", native, ""},
+		{"cross arch", TargetConfiguration{Arch: []system.Processor{host.DetectHostProcessor()}}, "cross", cross, "arch " + crossArch().String() + " is not one of " + host.DetectHostProcessor().String()},
+		{"toolchain glob", TargetConfiguration{Toolchains: []string{"clang*", "gcc"}}, "clang-18", native, ""},
+		{"other toolchain", TargetConfiguration{Toolchains: []string{"clang*"}}, "gcc", native, "toolchain gcc is not one of clang*"},
+	}
+
+	for _, tt := range tests {
+		target := &TargetContext{Name: "t", Config: tt.config}
+		got, err := target.UnsupportedReason(tt.tcName, tt.tc)
+		if err != nil {
+			t.Errorf("%s: UnsupportedReason() error = %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: UnsupportedReason() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	target := &TargetContext{Name: "t", Config: TargetConfiguration{Toolchains: []string{"["}}}
+	if _, err := target.UnsupportedReason("gcc", native); err == nil {
+		t.Errorf("UnsupportedReason() with pattern %q, want an error", "[")
+	}
+}
+
+func TestUnsupportedTargets(t *testing.T) {
+	ctx := context.Background()
+	w := &WorkspaceContext{
+		WorkspacePath: t.TempDir(),
+		Config: WorkspaceConfig{
+			Targets: map[string]*TargetConfiguration{
+				"winlib":  {Platforms: []system.Platform{system.PlatformWindows}},
+				"winapp":  {Depends: []string{"winlib"}, Platforms: []system.Platform{system.PlatformWindows}},
+				"app":     {Depends: []string{"winlib"}},
+				"tool":    {},
+				"wrapper": {Depends: []string{"app"}},
+				"top":     {Depends: []string{"wrapper"}},
+				"gcconly": {Toolchains: []string{"gcc"}},
+			},
+		},
+	}
+	writeTestToolchain(t, w, "linux", &Toolchain{TargetSystem: system.PlatformLinux})
+	bp := TargetBuildParameters{Toolchain: "linux"}
+
+	got, err := w.UnsupportedTargets(ctx, []string{"winlib", "winapp", "tool", "gcconly"}, bp)
+	if err != nil {
+		t.Fatalf("UnsupportedTargets() error = %v", err)
+	}
+	wrongPlatform := "platform " + system.PlatformLinux.String() + " is not one of " + system.PlatformWindows.String()
+	want := map[string]string{
+		"winlib":  wrongPlatform,
+		"winapp":  wrongPlatform,
+		"gcconly": "toolchain linux is not one of gcc",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnsupportedTargets() = %v, want %v", got, want)
+	}
+
+	// A dependency outside of order, as with --no-deps, is checked too,
+	// as are transitive dependencies.
+	for _, tt := range []struct {
+		order []string
+		want  string
+	}{
+		{[]string{"winlib", "app"}, "target app depends on winlib"},
+		{[]string{"app"}, "target app depends on winlib"},
+		{[]string{"wrapper"}, "target wrapper depends on winlib"},
+		{[]string{"top"}, "target top depends on winlib"},
+	} {
+		_, err = w.UnsupportedTargets(ctx, tt.order, bp)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("UnsupportedTargets(%v) error = %v, want %q", tt.order, err, tt.want)
+		}
+	}
+
+	if err := w.CheckTargetSupported(ctx, "tool", bp); err != nil {
+		t.Errorf("CheckTargetSupported(tool) error = %v", err)
+	}
+	if err := w.CheckTargetSupported(ctx, "winapp", bp); err == nil {
+		t.Errorf("CheckTargetSupported(winapp) = nil, want an error")
+	}
+	for _, name := range []string{"app", "top"} {
+		if err := w.CheckTargetSupported(ctx, name, bp); err == nil {
+			t.Errorf("CheckTargetSupported(%s) = nil, want an error", name)
+		}
+	}
+}
+
+func TestArchConstraintYAML(t *testing.T) {
+	var config TargetConfiguration
+	if err := yaml.Unmarshal([]byte("arch: [arm64, x86_64]"), &config); err != nil {
+		t.Fatalf("yaml.Unmarshal(arch: [arm64, x86_64]) error = %v", err)
+	}
+	if want := (ArchConstraint{system.ProcessorArm64, system.ProcessorX64}); !reflect.DeepEqual(config.Arch, want) {
+		t.Errorf("yaml.Unmarshal(arch: [arm64, x86_64]) = %v, want %v", config.Arch, want)
+	}
+
+	for _, s := range []string{"arch: [arm64, armv9]", "arch: [unknown]", "arch: arm64"} {
+		var config TargetConfiguration
+		if err := yaml.Unmarshal([]byte(s), &config); err == nil {
+			t.Errorf("yaml.Unmarshal(%s) = %v, want an error", s, config.Arch)
+		}
+	}
+
+	// A toolchain may target a processor cbuild does not know.
+	w := &WorkspaceContext{WorkspacePath: t.TempDir()}
+	writeTestFile(t, filepath.Join(w.toolchainDir("zlinux"), "toolchain.yml"), "target_system: linux\ntarget_arch: s390x\n")
+	tc, _, err := w.LoadToolchain(context.Background(), "zlinux")
+	if err != nil {
+		t.Fatalf("LoadToolchain(zlinux) error = %v", err)
+	}
+	if tc.TargetArch != system.ProcessorUnknown {
+		t.Errorf("LoadToolchain(zlinux) target_arch = %v, want unknown", tc.TargetArch)
+	}
+}
@@ -0,0 +1,248 @@
+package ccommon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type GraphNode struct {
+	Name   string      `json:"name"`
+	Source string      `json:"source"`
+	Staged bool        `json:"staged"`
+	Status BuildStatus `json:"status,omitempty"`
+}
+
+// GraphEdge is a dependency of From on To. Staged edges consume the staged
+// install of To, the others its build tree.
+type GraphEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Component string `json:"component,omitempty"`
+	Staged    bool   `json:"staged"`
+}
+
+type DependencyGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+type GraphOptions struct {
+	// From limits the graph to the given targets and their transitive dependencies.
+	From []string
+	// To limits the graph to the given targets and the targets that depend on them.
+	To []string
+	// Status adds the last build status of each target for BuildParameters,
+	// from w.State.
+	Status          bool
+	BuildParameters TargetBuildParameters
+}
+
+// TargetSourceName returns the name of the source a target is built from.
+func (t *TargetContext) TargetSourceName() string {
+	if t.Config.ExternalSourceOverride != nil {
+		return *t.Config.ExternalSourceOverride
+	}
+	if t.Config.Source != "" {
+		return t.Config.Source
+	}
+	return t.Name
+}
+
+// DependencyGraph returns the dependency graph of the workspace's targets.
+func (w *WorkspaceContext) DependencyGraph(ctx context.Context, opts GraphOptions) (*DependencyGraph, error) {
+	included := make(map[string]bool)
+	for _, name := range w.ListTargets(ctx) {
+		included[name] = true
+	}
+
+	if len(opts.From) > 0 {
+		deps, err := w.TargetBuildOrder(ctx, opts.From)
+		if err != nil {
+			return nil, err
+		}
+		included = intersect(included, deps)
+	}
+	if len(opts.To) > 0 {
+		dependents, err := w.TargetDependents(ctx, opts.To)
+		if err != nil {
+			return nil, err
+		}
+		included = intersect(included, append(dependents, opts.To...))
+	}
+
+	graph := &DependencyGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	for _, name := range w.ListTargets(ctx) {
+		if !included[name] {
+			continue
+		}
+		t, err := w.GetTarget(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		node := GraphNode{
+			Name:   name,
+			Source: t.TargetSourceName(),
+			Staged: t.Config.Staged != nil && *t.Config.Staged,
+		}
+		if opts.Status && w.State != nil {
+			node.Status = w.State.Status(opts.BuildParameters, name)
+		}
+		graph.Nodes = append(graph.Nodes, node)
+
+		for _, dep := range t.Config.Depends {
+			depName, component, _ := strings.Cut(dep, "/")
+			if !included[depName] {
+				continue
+			}
+			depTarget, err := w.GetTarget(ctx, depName)
+			if err != nil {
+				return nil, err
+			}
+			graph.Edges = append(graph.Edges, GraphEdge{
+				From:      name,
+				To:        depName,
+				Component: component,
+				Staged:    depTarget.Config.Staged != nil && *depTarget.Config.Staged,
+			})
+		}
+	}
+
+	return graph, nil
+}
+
+func intersect(set map[string]bool, names []string) map[string]bool {
+	result := make(map[string]bool)
+	for _, name := range names {
+		if set[name] {
+			result[name] = true
+		}
+	}
+	return result
+}
+
+func (e GraphEdge) label() string {
+	label := "build tree"
+	if e.Staged {
+		label = "staged"
+	}
+	if e.Component != "" {
+		label += ": " + e.Component
+	}
+	return label
+}
+
+func (n GraphNode) label() string {
+	if n.Source != n.Name {
+		return fmt.Sprintf("%s\n(source: %s)", n.Name, n.Source)
+	}
+	return n.Name
+}
+
+var statusColors = map[BuildStatus]string{
+	BuildStatusSucceeded: "#b7e4b0",
+	BuildStatusFailed:    "#f4a6a6",
+	BuildStatusPending:   "#dddddd",
+}
+
+// dotQuote quotes s as a DOT string. Unlike strconv.Quote it only uses escapes
+// that DOT understands.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\r", "")
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// WriteDOT writes the graph in Graphviz DOT format.
+func (g *DependencyGraph) WriteDOT(out io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph workspace {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+
+	for _, n := range g.Nodes {
+		attrs := []string{"label=" + dotQuote(n.label())}
+		if n.Staged {
+			attrs = append(attrs, "peripheries=2")
+		}
+		if color, ok := statusColors[n.Status]; ok {
+			attrs = append(attrs, "style=filled", "fillcolor="+dotQuote(color))
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(n.Name), strings.Join(attrs, ", "))
+	}
+
+	for _, e := range g.Edges {
+		style := "solid"
+		if e.Staged {
+			style = "dashed"
+		}
+		fmt.Fprintf(&b, "  %s -> %s [label=%s, style=%s];\n", dotQuote(e.From), dotQuote(e.To), dotQuote(e.label()), style)
+	}
+
+	b.WriteString("}\n")
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart.
+func (g *DependencyGraph) WriteMermaid(out io.Writer) error {
+	ids := make(map[string]string)
+	for i, n := range g.Nodes {
+		ids[n.Name] = fmt.Sprintf("n%d", i)
+	}
+	escaper := strings.NewReplacer("#", "#35;", `"`, "#quot;", "\r", "", "\n", "<br/>")
+	quote := func(s string) string {
+		return `"` + escaper.Replace(s) + `"`
+	}
+
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+
+	for _, n := range g.Nodes {
+		if n.Staged {
+			fmt.Fprintf(&b, "  %s[[%s]]\n", ids[n.Name], quote(n.label()))
+		} else {
+			fmt.Fprintf(&b, "  %s[%s]\n", ids[n.Name], quote(n.label()))
+		}
+	}
+
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Staged {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s|%s| %s\n", ids[e.From], arrow, quote(e.label()), ids[e.To])
+	}
+
+	for _, status := range []BuildStatus{BuildStatusSucceeded, BuildStatusFailed, BuildStatusPending} {
+		var members []string
+		for _, n := range g.Nodes {
+			if n.Status == status {
+				members = append(members, ids[n.Name])
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "  classDef %s fill:%s\n", status, statusColors[status])
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(members, ","), status)
+	}
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// WriteJSON writes the graph as indented JSON.
+func (g *DependencyGraph) WriteJSON(out io.Writer) error {
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(append(data, '\n'))
+	return err
+}
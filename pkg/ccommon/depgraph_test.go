@@ -0,0 +1,150 @@
+package ccommon
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// graphWorkspace has the graph
+//
+//	app -> lib -> base/headers
+//	app -> base
+//	other
+func graphWorkspace() *WorkspaceContext {
+	staged := true
+	return &WorkspaceContext{
+		Config: WorkspaceConfig{
+			Targets: map[string]*TargetConfiguration{
+				"base":  {Staged: &staged},
+				"lib":   {Depends: []string{"base/headers"}, Source: "libsrc"},
+				"app":   {Depends: []string{"lib", "base"}},
+				"other": nil,
+			},
+		},
+	}
+}
+
+func TestDependencyGraphFilter(t *testing.T) {
+	w := graphWorkspace()
+
+	tests := []struct {
+		name      string
+		opts      GraphOptions
+		wantNodes []string
+		wantEdges []string
+	}{
+		{"all", GraphOptions{}, []string{"app", "base", "lib", "other"}, []string{"app->lib", "app->base", "lib->base/headers"}},
+		{"from", GraphOptions{From: []string{"lib"}}, []string{"base", "lib"}, []string{"lib->base/headers"}},
+		{"to", GraphOptions{To: []string{"lib"}}, []string{"app", "lib"}, []string{"app->lib"}},
+		{"from and to", GraphOptions{From: []string{"app"}, To: []string{"lib"}}, []string{"app", "lib"}, []string{"app->lib"}},
+		{"unrelated", GraphOptions{From: []string{"other"}}, []string{"other"}, nil},
+	}
+
+	for _, tt := range tests {
+		g, err := w.DependencyGraph(context.Background(), tt.opts)
+		if err != nil {
+			t.Errorf("%s: DependencyGraph error = %v", tt.name, err)
+			continue
+		}
+		var nodes, edges []string
+		for _, n := range g.Nodes {
+			nodes = append(nodes, n.Name)
+		}
+		for _, e := range g.Edges {
+			edge := e.From + "->" + e.To
+			if e.Component != "" {
+				edge += "/" + e.Component
+			}
+			edges = append(edges, edge)
+		}
+		if !reflect.DeepEqual(nodes, tt.wantNodes) {
+			t.Errorf("%s: nodes = %v, want %v", tt.name, nodes, tt.wantNodes)
+		}
+		if !reflect.DeepEqual(edges, tt.wantEdges) {
+			t.Errorf("%s: edges = %v, want %v", tt.name, edges, tt.wantEdges)
+		}
+	}
+}
+
+func TestDependencyGraphWrite(t *testing.T) {
+	g, err := graphWorkspace().DependencyGraph(context.Background(), GraphOptions{From: []string{"lib"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dot strings.Builder
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	wantDOT := `digraph workspace {
+  rankdir=LR;
+  node [shape=box];
+  "base" [label="base", peripheries=2];
+  "lib" [label="lib\n(source: libsrc)"];
+  "lib" -> "base" [label="staged: headers", style=dashed];
+}
+`
+	if dot.String() != wantDOT {
+		t.Errorf("WriteDOT =\n%s\nwant\n%s", dot.String(), wantDOT)
+	}
+
+	var mermaid strings.Builder
+	if err := g.WriteMermaid(&mermaid); err != nil {
+		t.Fatal(err)
+	}
+	wantMermaid := `graph LR
+  n0[["base"]]
+  n1["lib<br/>(source: libsrc)"]
+  n1 -.->|"staged: headers"| n0
+`
+	if mermaid.String() != wantMermaid {
+		t.Errorf("WriteMermaid =\n%s\nwant\n%s", mermaid.String(), wantMermaid)
+	}
+}
+
+func TestDependencyGraphEscaping(t *testing.T) {
+	staged := true
+	w := &WorkspaceContext{
+		Config: WorkspaceConfig{
+			Targets: map[string]*TargetConfiguration{
+				`say "hi"`:    {Depends: []string{"multi\nline/c#1"}, Source: `back\slash`},
+				"multi\nline": {Staged: &staged},
+			},
+		},
+	}
+	g, err := w.DependencyGraph(context.Background(), GraphOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dot strings.Builder
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	wantDOT := `digraph workspace {
+  rankdir=LR;
+  node [shape=box];
+  "multi\nline" [label="multi\nline", peripheries=2];
+  "say \"hi\"" [label="say \"hi\"\n(source: back\\slash)"];
+  "say \"hi\"" -> "multi\nline" [label="staged: c#1", style=dashed];
+}
+`
+	if dot.String() != wantDOT {
+		t.Errorf("WriteDOT =\n%s\nwant\n%s", dot.String(), wantDOT)
+	}
+
+	var mermaid strings.Builder
+	if err := g.WriteMermaid(&mermaid); err != nil {
+		t.Fatal(err)
+	}
+	wantMermaid := `graph LR
+  n0[["multi<br/>line"]]
+  n1["say #quot;hi#quot;<br/>(source: back\slash)"]
+  n1 -.->|"staged: c#35;1"| n0
+`
+	if mermaid.String() != wantMermaid {
+		t.Errorf("WriteMermaid =\n%s\nwant\n%s", mermaid.String(), wantMermaid)
+	}
+}
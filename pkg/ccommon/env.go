@@ -0,0 +1,259 @@
+package ccommon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/host"
+	"gitlab.com/rpnx/cbuild-go/pkg/system"
+)
+
+// EnvVar is a path-list environment variable. Paths are prepended to any
+// existing value, unless Replace is set.
+type EnvVar struct {
+	Name    string
+	Paths   []string
+	Replace bool
+}
+
+type RuntimeEnvironment []EnvVar
+
+// LibraryPathVar returns the name of the variable the host's dynamic loader
+// searches for shared libraries.
+func LibraryPathVar() string {
+	switch host.DetectHostPlatform() {
+	case system.PlatformMac:
+		return "DYLD_LIBRARY_PATH"
+	case system.PlatformWindows:
+		return "PATH"
+	}
+	return "LD_LIBRARY_PATH"
+}
+
+// Value returns the value of the variable with its paths prepended to existing,
+// or only its paths if it replaces the existing value.
+func (v EnvVar) Value(existing string) string {
+	paths := append([]string{}, v.Paths...)
+	if existing != "" && !v.Replace {
+		paths = append(paths, existing)
+	}
+	return strings.Join(paths, string(os.PathListSeparator))
+}
+
+// Environ returns environ with the runtime environment applied to it.
+func (e RuntimeEnvironment) Environ(environ []string) []string {
+	values := make(map[string]string)
+	var order []string
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if _, ok := values[name]; !ok {
+			order = append(order, name)
+		}
+		values[name] = value
+	}
+
+	for _, v := range e {
+		if _, ok := values[v.Name]; !ok {
+			order = append(order, v.Name)
+		}
+		values[v.Name] = v.Value(values[v.Name])
+	}
+
+	result := make([]string, 0, len(order))
+	for _, name := range order {
+		result = append(result, name+"="+values[name])
+	}
+	return result
+}
+
+// WriteBash writes the environment as POSIX shell export commands.
+func (e RuntimeEnvironment) WriteBash(out io.Writer) error {
+	var b strings.Builder
+	for _, v := range e {
+		if len(v.Paths) == 0 {
+			continue
+		}
+		value := shellQuote(strings.Join(v.Paths, string(os.PathListSeparator)))
+		if v.Replace {
+			fmt.Fprintf(&b, "export %s=%s\n", v.Name, value)
+		} else {
+			fmt.Fprintf(&b, "export %s=%s\"${%s:+%c$%s}\"\n", v.Name, value, v.Name, os.PathListSeparator, v.Name)
+		}
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// WriteFish writes the environment as fish set commands.
+func (e RuntimeEnvironment) WriteFish(out io.Writer) error {
+	var b strings.Builder
+	for _, v := range e {
+		if len(v.Paths) == 0 {
+			continue
+		}
+		quoted := make([]string, len(v.Paths))
+		for i, p := range v.Paths {
+			quoted[i] = shellQuote(p)
+		}
+		fmt.Fprintf(&b, "set -gx %s %s", v.Name, strings.Join(quoted, " "))
+		if !v.Replace {
+			fmt.Fprintf(&b, " $%s", v.Name)
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// shellQuote quotes s for POSIX shells and fish, which both end a single
+// quoted string at ' and accept an escaped \'.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// RuntimeEnvironment computes the PATH, library path, PKG_CONFIG_PATH and
+// CMAKE_PREFIX_PATH needed to run a target against the staging directories
+// and build trees of itself and its transitive dependencies. Only directories
+// that exist are included.
+func (w *WorkspaceContext) RuntimeEnvironment(ctx context.Context, targetName string, bp TargetBuildParameters) (RuntimeEnvironment, error) {
+	err := w.CheckTargetSupported(ctx, targetName, bp)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := w.TargetBuildOrder(ctx, []string{targetName})
+	if err != nil {
+		return nil, err
+	}
+
+	var binPaths, libPaths, pkgConfigPaths, prefixPaths []string
+
+	// The target itself comes last in build order, but its paths should take
+	// precedence over those of its dependencies.
+	for i := len(order) - 1; i >= 0; i-- {
+		t, err := w.GetTarget(ctx, order[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if t.Config.Staged != nil && *t.Config.Staged {
+			stagingPath, err := t.CMakeStagingPath(ctx, w, bp)
+			if err != nil {
+				return nil, err
+			}
+			stagingPath, err = filepath.Abs(stagingPath)
+			if err != nil {
+				return nil, err
+			}
+
+			binPaths = appendExisting(binPaths, filepath.Join(stagingPath, "bin"))
+			libPaths = appendExisting(libPaths, filepath.Join(stagingPath, "lib"), filepath.Join(stagingPath, "lib64"))
+			pkgConfigPaths = appendExisting(pkgConfigPaths, StagingPkgConfigDirs(stagingPath)...)
+			prefixPaths = appendExisting(prefixPaths, stagingPath)
+			continue
+		}
+
+		buildPath, err := t.CMakeBuildPath(ctx, w, bp)
+		if err != nil {
+			return nil, err
+		}
+		buildPath, err = filepath.Abs(buildPath)
+		if err != nil {
+			return nil, err
+		}
+		configPath, err := t.CMakeConfigPath(ctx, w, bp)
+		if err != nil {
+			return nil, err
+		}
+		configPath, err = filepath.Abs(configPath)
+		if err != nil {
+			return nil, err
+		}
+
+		binPaths = appendExisting(binPaths, buildPath, filepath.Join(buildPath, "bin"))
+		libPaths = appendExisting(libPaths, sharedLibraryDirs(buildPath)...)
+		prefixPaths = appendExisting(prefixPaths, configPath)
+	}
+
+	env := RuntimeEnvironment{
+		{Name: "PATH", Paths: binPaths},
+	}
+	if libVar := LibraryPathVar(); libVar == "PATH" {
+		env[0].Paths = append(env[0].Paths, libPaths...)
+	} else {
+		env = append(env, EnvVar{Name: libVar, Paths: libPaths})
+	}
+	env = append(env,
+		EnvVar{Name: "PKG_CONFIG_PATH", Paths: pkgConfigPaths},
+		EnvVar{Name: "CMAKE_PREFIX_PATH", Paths: prefixPaths},
+	)
+	return env, nil
+}
+
+// StagingPkgConfigDirs returns the directories pkg-config files are installed
+// to under a staging prefix.
+func StagingPkgConfigDirs(stagingPath string) []string {
+	return []string{
+		filepath.Join(stagingPath, "lib", "pkgconfig"),
+		filepath.Join(stagingPath, "lib64", "pkgconfig"),
+		filepath.Join(stagingPath, "share", "pkgconfig"),
+	}
+}
+
+func appendExisting(paths []string, candidates ...string) []string {
+	for _, p := range candidates {
+		if info, err := os.Stat(p); err != nil || !info.IsDir() {
+			continue
+		}
+		found := false
+		for _, existing := range paths {
+			if existing == p {
+				found = true
+				break
+			}
+		}
+		if !found {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+func isSharedLibrary(name string) bool {
+	return strings.HasSuffix(name, ".so") || strings.Contains(name, ".so.") ||
+		strings.HasSuffix(name, ".dylib") || strings.HasSuffix(name, ".dll")
+}
+
+// sharedLibraryDirs returns the directories in a build tree that contain
+// shared libraries, skipping CMake's internal directories.
+func sharedLibraryDirs(buildPath string) []string {
+	dirs := make(map[string]bool)
+	filepath.WalkDir(buildPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == "CMakeFiles" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isSharedLibrary(d.Name()) {
+			dirs[filepath.Dir(path)] = true
+		}
+		return nil
+	})
+
+	result := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		result = append(result, dir)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,112 @@
+package ccommon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeEnvironment(t *testing.T) {
+	staged := true
+	w := &WorkspaceContext{
+		WorkspacePath: t.TempDir(),
+		Config: WorkspaceConfig{
+			Targets: map[string]*TargetConfiguration{
+				"app":  {Depends: []string{"util", "lib"}},
+				"util": {},
+				"lib":  {Staged: &staged},
+			},
+		},
+	}
+	writeTestToolchain(t, w, "tc", &Toolchain{})
+	bp := TargetBuildParameters{Toolchain: "tc", BuildType: "Debug"}
+
+	appBuild := filepath.Join(w.WorkspacePath, "buildspaces", "tc", "app", "Debug")
+	utilBuild := filepath.Join(w.WorkspacePath, "buildspaces", "tc", "util", "Debug")
+	libStaging := filepath.Join(w.WorkspacePath, "staging", "tc", "Debug", "lib")
+	writeTestFile(t, filepath.Join(appBuild, "bin", "app"), "")
+	writeTestFile(t, filepath.Join(appBuild, "src", "libapp.so"), "")
+	writeTestFile(t, filepath.Join(appBuild, "CMakeFiles", "libignored.so"), "")
+	writeTestFile(t, filepath.Join(utilBuild, "libutil.so.1"), "")
+	writeTestFile(t, filepath.Join(libStaging, "bin", "lib-config"), "")
+	writeTestFile(t, filepath.Join(libStaging, "lib", "liblib.so"), "")
+	writeTestFile(t, filepath.Join(libStaging, "lib", "pkgconfig", "lib.pc"), "")
+
+	env, err := w.RuntimeEnvironment(context.Background(), "app", bp)
+	if err != nil {
+		t.Fatalf("RuntimeEnvironment() error = %v", err)
+	}
+	got := make(map[string][]string)
+	for _, v := range env {
+		got[v.Name] = append(got[v.Name], v.Paths...)
+	}
+
+	// The build order is util, lib, app, so app comes first and util last.
+	// Directories that do not exist are left out.
+	bin := []string{appBuild, filepath.Join(appBuild, "bin"), filepath.Join(libStaging, "bin"), utilBuild}
+	lib := []string{filepath.Join(appBuild, "src"), filepath.Join(libStaging, "lib"), utilBuild}
+	want := map[string][]string{
+		"PKG_CONFIG_PATH":   {filepath.Join(libStaging, "lib", "pkgconfig")},
+		"CMAKE_PREFIX_PATH": {appBuild, libStaging, utilBuild},
+	}
+	if libVar := LibraryPathVar(); libVar == "PATH" {
+		want["PATH"] = append(bin, lib...)
+	} else {
+		want["PATH"] = bin
+		want[libVar] = lib
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RuntimeEnvironment() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestRuntimeEnvironmentWrite(t *testing.T) {
+	sep := string(os.PathListSeparator)
+	env := RuntimeEnvironment{
+		{Name: "PATH", Paths: []string{"/ws/it's/bin", "/ws/lib bin"}},
+		{Name: "LD_LIBRARY_PATH"},
+		{Name: "PKG_CONFIG_LIBDIR", Paths: []string{"/staging"}, Replace: true},
+	}
+
+	var bash strings.Builder
+	if err := env.WriteBash(&bash); err != nil {
+		t.Fatal(err)
+	}
+	wantBash := `export PATH='/ws/it'\''s/bin` + sep + `/ws/lib bin'"${PATH:+` + sep + `$PATH}"` + "\n" +
+		"export PKG_CONFIG_LIBDIR='/staging'\n"
+	if bash.String() != wantBash {
+		t.Errorf("WriteBash() =\n%s\nwant\n%s", bash.String(), wantBash)
+	}
+
+	var fish strings.Builder
+	if err := env.WriteFish(&fish); err != nil {
+		t.Fatal(err)
+	}
+	wantFish := `set -gx PATH '/ws/it'\''s/bin' '/ws/lib bin' $PATH` + "\n" +
+		"set -gx PKG_CONFIG_LIBDIR '/staging'\n"
+	if fish.String() != wantFish {
+		t.Errorf("WriteFish() =\n%s\nwant\n%s", fish.String(), wantFish)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "''"},
+		{"/usr/bin", "'/usr/bin'"},
+		{"it's", `'it'\''s'`},
+		{"''", `''\'''\'''`},
+		{"$HOME `x` \"y\"", "'$HOME `x` \"y\"'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
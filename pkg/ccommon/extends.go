@@ -0,0 +1,132 @@
+package ccommon
+
+import (
+	"gitlab.com/rpnx/cbuild-go/pkg/cmake"
+	"gitlab.com/rpnx/cbuild-go/pkg/system"
+)
+
+// mergeToolchain returns the child toolchain merged on top of the toolchain it
+// extends. Settings of the child take precedence, list fields are appended to
+// the parent's. Toolchain files of the parent are made absolute, as they are
+// relative to the parent's directory.
+func mergeToolchain(parent *Toolchain, parentDir string, child *Toolchain) *Toolchain {
+	merged := &Toolchain{
+		Extends:          child.Extends,
+		TargetArch:       parent.TargetArch,
+		TargetSystem:     parent.TargetSystem,
+		CompilerLauncher: parent.CompilerLauncher,
+	}
+	if child.CompilerLauncher != "" {
+		merged.CompilerLauncher = child.CompilerLauncher
+	}
+	if child.TargetArch != system.ProcessorUnknown {
+		merged.TargetArch = child.TargetArch
+	}
+	if child.TargetSystem != system.PlatformUnknown {
+		merged.TargetSystem = child.TargetSystem
+	}
+
+	if len(parent.CMakeToolchain) > 0 || len(child.CMakeToolchain) > 0 {
+		merged.CMakeToolchain = make(map[string]CMakeToolchainOptions)
+	}
+	for host, opts := range parent.CMakeToolchain {
+		opts.CMakeToolchainFile = opts.ResolveToolchainFile(parentDir)
+		merged.CMakeToolchain[host] = opts
+	}
+	for host, opts := range child.CMakeToolchain {
+		base, ok := merged.CMakeToolchain[host]
+		if !ok || opts.CMakeToolchainFile != "" {
+			merged.CMakeToolchain[host] = opts
+			continue
+		}
+		if opts.Generate != nil {
+			base.CMakeToolchainFile = ""
+			base.Generate = mergeGenerateOptions(base.Generate, opts.Generate)
+		}
+		merged.CMakeToolchain[host] = base
+	}
+
+	if len(parent.Configurations) > 0 || len(child.Configurations) > 0 {
+		merged.Configurations = make(map[string]*cmake.ConfigurationDefinition)
+	}
+	for name, def := range parent.Configurations {
+		merged.Configurations[name] = def
+	}
+	for name, def := range child.Configurations {
+		merged.Configurations[name] = def
+	}
+
+	return merged
+}
+
+func mergeGenerateOptions(parent *CMakeGenerateToolchainFileOptions, child *CMakeGenerateToolchainFileOptions) *CMakeGenerateToolchainFileOptions {
+	if parent == nil {
+		return child
+	}
+
+	merged := *parent
+	if child.CompilerType != cmake.CompilerTypeUnknown {
+		merged.CompilerType = child.CompilerType
+	}
+	// A recorded compiler identity only applies to the compiler it was
+	// recorded for.
+	if child.CCompiler != "" {
+		merged.CCompiler = child.CCompiler
+		merged.CCompilerIdentity = child.CCompilerIdentity
+	}
+	if child.CXXCompiler != "" {
+		merged.CXXCompiler = child.CXXCompiler
+		merged.CXXCompilerIdentity = child.CXXCompilerIdentity
+	}
+	if child.Linker != "" {
+		merged.Linker = child.Linker
+	}
+	if child.LinkerType != cmake.LinkerTypeUnknown {
+		merged.LinkerType = child.LinkerType
+	}
+	if child.Sysroot != "" {
+		merged.Sysroot = child.Sysroot
+	}
+	if child.TargetTriple != "" {
+		merged.TargetTriple = child.TargetTriple
+	}
+
+	merged.ExtraCompilerFlags = appendFlags(parent.ExtraCompilerFlags, child.ExtraCompilerFlags)
+	merged.ExtraCFlags = appendFlags(parent.ExtraCFlags, child.ExtraCFlags)
+	merged.ExtraCXXFlags = appendFlags(parent.ExtraCXXFlags, child.ExtraCXXFlags)
+	merged.ExtraLinkerFlags = appendFlags(parent.ExtraLinkerFlags, child.ExtraLinkerFlags)
+	merged.ExtraSharedLinkerFlags = appendFlags(parent.ExtraSharedLinkerFlags, child.ExtraSharedLinkerFlags)
+	merged.ExtraExeLinkerFlags = appendFlags(parent.ExtraExeLinkerFlags, child.ExtraExeLinkerFlags)
+	merged.FindRootPath = appendFlags(parent.FindRootPath, child.FindRootPath)
+
+	if child.FindRootPathModes != nil {
+		modes := cmake.FindRootPathModes{}
+		if parent.FindRootPathModes != nil {
+			modes = *parent.FindRootPathModes
+		}
+		if child.FindRootPathModes.Program != "" {
+			modes.Program = child.FindRootPathModes.Program
+		}
+		if child.FindRootPathModes.Library != "" {
+			modes.Library = child.FindRootPathModes.Library
+		}
+		if child.FindRootPathModes.Include != "" {
+			modes.Include = child.FindRootPathModes.Include
+		}
+		if child.FindRootPathModes.Package != "" {
+			modes.Package = child.FindRootPathModes.Package
+		}
+		merged.FindRootPathModes = &modes
+	}
+
+	return &merged
+}
+
+func appendFlags(parent []string, child []string) []string {
+	if len(parent) == 0 && len(child) == 0 {
+		return nil
+	}
+	flags := make([]string, 0, len(parent)+len(child))
+	flags = append(flags, parent...)
+	return append(flags, child...)
+}
@@ -0,0 +1,278 @@
+package ccommon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/cmake"
+	"gitlab.com/rpnx/cbuild-go/pkg/system"
+)
+
+func TestMergeToolchain(t *testing.T) {
+	parentDir := "/work/toolchains/base"
+	cID := &CompilerIdentity{Path: "/usr/bin/gcc-13", SHA256: "aaaa"}
+	cxxID := &CompilerIdentity{Path: "/usr/bin/g++-13", SHA256: "bbbb"}
+	clangID := &CompilerIdentity{Path: "/usr/bin/clang-18", SHA256: "cccc"}
+
+	tests := []struct {
+		name   string
+		parent *Toolchain
+		child  *Toolchain
+		want   *Toolchain
+	}{
+		{
+			name:   "settings",
+			parent: &Toolchain{TargetSystem: system.PlatformLinux, TargetArch: system.ProcessorX64, CompilerLauncher: "ccache"},
+			child:  &Toolchain{Extends: "base", TargetArch: system.ProcessorArm64},
+			want:   &Toolchain{Extends: "base", TargetSystem: system.PlatformLinux, TargetArch: system.ProcessorArm64, CompilerLauncher: "ccache"},
+		},
+		{
+			name: "lists are appended",
+			parent: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{
+				CCompiler:     "gcc",
+				ExtraCXXFlags: []string{"-Wall"},
+				FindRootPath:  []string{"/opt/a"},
+			}}}},
+			child: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{
+				ExtraCXXFlags:    []string{"-Werror"},
+				ExtraLinkerFlags: []string{"-fuse-ld=mold"},
+				FindRootPath:     []string{"/opt/b"},
+			}}}},
+			want: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{
+				CCompiler:        "gcc",
+				ExtraCXXFlags:    []string{"-Wall", "-Werror"},
+				ExtraLinkerFlags: []string{"-fuse-ld=mold"},
+				FindRootPath:     []string{"/opt/a", "/opt/b"},
+			}}}},
+		},
+		{
+			name: "host entries are merged",
+			parent: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{
+				"host-linux-x64":   {Generate: &CMakeGenerateToolchainFileOptions{CCompiler: "gcc", Sysroot: "/sysroot"}},
+				"host-linux-arm64": {Generate: &CMakeGenerateToolchainFileOptions{CCompiler: "gcc"}},
+			}},
+			child: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{
+				"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{
+					TargetTriple:      "aarch64-linux-gnu",
+					FindRootPathModes: &cmake.FindRootPathModes{Library: "BOTH"},
+				}},
+				"host-macos-arm64": {Generate: &CMakeGenerateToolchainFileOptions{CCompiler: "clang"}},
+			}},
+			want: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{
+				"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{
+					CCompiler:         "gcc",
+					Sysroot:           "/sysroot",
+					TargetTriple:      "aarch64-linux-gnu",
+					FindRootPathModes: &cmake.FindRootPathModes{Library: "BOTH"},
+				}},
+				"host-linux-arm64": {Generate: &CMakeGenerateToolchainFileOptions{CCompiler: "gcc"}},
+				"host-macos-arm64": {Generate: &CMakeGenerateToolchainFileOptions{CCompiler: "clang"}},
+			}},
+		},
+		{
+			name:   "parent toolchain file is rebased",
+			parent: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {CMakeToolchainFile: "toolchain.cmake"}}},
+			child:  &Toolchain{Extends: "base"},
+			want: &Toolchain{Extends: "base", CMakeToolchain: map[string]CMakeToolchainOptions{
+				"host-linux-x64": {CMakeToolchainFile: filepath.Join(parentDir, "toolchain.cmake")},
+			}},
+		},
+		{
+			name:   "absolute parent toolchain file is kept",
+			parent: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {CMakeToolchainFile: "/opt/toolchain.cmake"}}},
+			child:  &Toolchain{},
+			want:   &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {CMakeToolchainFile: "/opt/toolchain.cmake"}}},
+		},
+		{
+			name:   "file based parent replaced by generated child",
+			parent: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {CMakeToolchainFile: "toolchain.cmake"}}},
+			child:  &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{CCompiler: "clang"}}}},
+			want:   &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{CCompiler: "clang"}}}},
+		},
+		{
+			name:   "generated parent replaced by file based child",
+			parent: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{CCompiler: "gcc"}}}},
+			child:  &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {CMakeToolchainFile: "child.cmake"}}},
+			want:   &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {CMakeToolchainFile: "child.cmake"}}},
+		},
+		{
+			name: "identity carried over for unchanged compilers",
+			parent: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{
+				CCompiler: "gcc-13", CXXCompiler: "g++-13", CCompilerIdentity: cID, CXXCompilerIdentity: cxxID,
+			}}}},
+			child: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{
+				CXXCompiler: "clang++-18",
+			}}}},
+			want: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{
+				CCompiler: "gcc-13", CXXCompiler: "clang++-18", CCompilerIdentity: cID,
+			}}}},
+		},
+		{
+			name: "identity of a changed compiler comes from the child",
+			parent: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{
+				CCompiler: "gcc-13", CCompilerIdentity: cID,
+			}}}},
+			child: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{
+				CCompiler: "clang-18", CCompilerIdentity: clangID,
+			}}}},
+			want: &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{"host-linux-x64": {Generate: &CMakeGenerateToolchainFileOptions{
+				CCompiler: "clang-18", CCompilerIdentity: clangID,
+			}}}},
+		},
+		{
+			name: "configurations",
+			parent: &Toolchain{Configurations: map[string]*cmake.ConfigurationDefinition{
+				"Fast":  {Inherits: "Release"},
+				"Check": {Inherits: "Debug"},
+			}},
+			child: &Toolchain{Configurations: map[string]*cmake.ConfigurationDefinition{
+				"Fast": {Inherits: "RelWithDebInfo"},
+			}},
+			want: &Toolchain{Configurations: map[string]*cmake.ConfigurationDefinition{
+				"Fast":  {Inherits: "RelWithDebInfo"},
+				"Check": {Inherits: "Debug"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		got := mergeToolchain(tt.parent, parentDir, tt.child)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: mergeToolchain() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMergeGenerateOptionsDoesNotModifyParent(t *testing.T) {
+	parent := &CMakeGenerateToolchainFileOptions{
+		ExtraCFlags:       make([]string, 1, 4),
+		FindRootPathModes: &cmake.FindRootPathModes{Program: "NEVER"},
+	}
+	parent.ExtraCFlags[0] = "-O2"
+	child := &CMakeGenerateToolchainFileOptions{
+		ExtraCFlags:       []string{"-g"},
+		FindRootPathModes: &cmake.FindRootPathModes{Program: "BOTH"},
+	}
+
+	merged := mergeGenerateOptions(parent, child)
+	other := mergeGenerateOptions(parent, &CMakeGenerateToolchainFileOptions{ExtraCFlags: []string{"-pg"}})
+
+	if !reflect.DeepEqual(merged.ExtraCFlags, []string{"-O2", "-g"}) || !reflect.DeepEqual(other.ExtraCFlags, []string{"-O2", "-pg"}) {
+		t.Errorf("mergeGenerateOptions() extra_c_flags = %q and %q, want [-O2 -g] and [-O2 -pg]", merged.ExtraCFlags, other.ExtraCFlags)
+	}
+	if parent.FindRootPathModes.Program != "NEVER" {
+		t.Errorf("mergeGenerateOptions() changed the parent's find_root_path_mode.program to %s", parent.FindRootPathModes.Program)
+	}
+}
+
+func TestLoadToolchainExtends(t *testing.T) {
+	ctx := context.Background()
+	w := &WorkspaceContext{WorkspacePath: t.TempDir()}
+	hostKey := HostToolchainKey()
+
+	writeTestToolchain(t, w, "base", &Toolchain{
+		TargetSystem:   system.PlatformLinux,
+		CMakeToolchain: map[string]CMakeToolchainOptions{hostKey: {CMakeToolchainFile: "base.cmake"}},
+	})
+	writeTestToolchain(t, w, "middle", &Toolchain{Extends: "base", CompilerLauncher: "ccache"})
+	writeTestToolchain(t, w, "leaf", &Toolchain{Extends: "middle", TargetArch: system.ProcessorArm64})
+
+	got, dir, err := w.LoadToolchain(ctx, "leaf")
+	if err != nil {
+		t.Fatalf("LoadToolchain(leaf) error = %v", err)
+	}
+	if dir != w.toolchainDir("leaf") {
+		t.Errorf("LoadToolchain(leaf) dir = %s, want %s", dir, w.toolchainDir("leaf"))
+	}
+	want := &Toolchain{
+		Extends:          "middle",
+		TargetSystem:     system.PlatformLinux,
+		TargetArch:       system.ProcessorArm64,
+		CompilerLauncher: "ccache",
+		CMakeToolchain:   map[string]CMakeToolchainOptions{hostKey: {CMakeToolchainFile: filepath.Join(w.toolchainDir("base"), "base.cmake")}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadToolchain(leaf) = %+v, want %+v", got, want)
+	}
+
+	writeTestToolchain(t, w, "a", &Toolchain{Extends: "b"})
+	writeTestToolchain(t, w, "b", &Toolchain{Extends: "a"})
+	writeTestToolchain(t, w, "self", &Toolchain{Extends: "self"})
+	writeTestToolchain(t, w, "orphan", &Toolchain{Extends: "missing"})
+
+	for _, tt := range []struct {
+		name string
+		want string
+	}{
+		{"a", "cycle detected: a -> b -> a"},
+		{"b", "cycle detected: b -> a -> b"},
+		{"self", "cycle detected: self -> self"},
+		{"orphan", "toolchain orphan extends missing"},
+	} {
+		_, _, err := w.LoadToolchain(ctx, tt.name)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadToolchain(%s) error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRemoveToolchainWithChildren(t *testing.T) {
+	ctx := context.Background()
+	w := &WorkspaceContext{WorkspacePath: t.TempDir()}
+	writeTestToolchain(t, w, "base", &Toolchain{})
+	writeTestToolchain(t, w, "child", &Toolchain{Extends: "base"})
+	// A directory without a toolchain.yml does not stop the check.
+	if err := os.MkdirAll(w.toolchainDir("stray"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := w.RemoveToolchain(ctx, "base", false)
+	if err == nil || !strings.Contains(err.Error(), "extended by child") {
+		t.Errorf("RemoveToolchain(base) error = %v, want extended by child", err)
+	}
+	if _, err := os.Stat(w.toolchainDir("base")); err != nil {
+		t.Errorf("RemoveToolchain(base) removed the toolchain: %v", err)
+	}
+
+	if err := w.RemoveToolchain(ctx, "child", false); err != nil {
+		t.Fatalf("RemoveToolchain(child) error = %v", err)
+	}
+	if err := w.RemoveToolchain(ctx, "base", false); err != nil {
+		t.Errorf("RemoveToolchain(base) without children error = %v", err)
+	}
+}
+
+func TestRenameToolchainUpdatesChildren(t *testing.T) {
+	ctx := context.Background()
+	w := &WorkspaceContext{WorkspacePath: t.TempDir()}
+	writeTestToolchain(t, w, "base", &Toolchain{CompilerLauncher: "ccache"})
+	writeTestToolchain(t, w, "child", &Toolchain{Extends: "base"})
+	writeTestToolchain(t, w, "grandchild", &Toolchain{Extends: "child"})
+	writeTestToolchain(t, w, "other", &Toolchain{})
+
+	if err := w.RenameToolchain(ctx, "base", "gcc"); err != nil {
+		t.Fatalf("RenameToolchain(base, gcc) error = %v", err)
+	}
+
+	for name, want := range map[string]string{"child": "gcc", "grandchild": "child", "other": ""} {
+		tc, _, err := w.readToolchain(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.Extends != want {
+			t.Errorf("after RenameToolchain(base, gcc), %s extends %q, want %q", name, tc.Extends, want)
+		}
+	}
+
+	tc, _, err := w.LoadToolchain(ctx, "grandchild")
+	if err != nil {
+		t.Fatalf("LoadToolchain(grandchild) error = %v", err)
+	}
+	if tc.CompilerLauncher != "ccache" {
+		t.Errorf("LoadToolchain(grandchild) compiler_launcher = %q, want ccache", tc.CompilerLauncher)
+	}
+}
@@ -17,6 +17,47 @@ const (
 	FlagNoSetup   FlagKey = "no-setup"
 	FlagHelp      FlagKey = "help"
 	FlagSource    FlagKey = "source"
+
+	FlagResume     FlagKey = "resume"
+	FlagOnlyFailed FlagKey = "only-failed"
+	FlagMaxAge     FlagKey = "max-age"
+	FlagMaxSize    FlagKey = "max-size"
+	FlagListen     FlagKey = "listen"
+	FlagCacheDir   FlagKey = "cache-dir"
+	FlagReadOnly   FlagKey = "read-only"
+	FlagMaxUpload  FlagKey = "max-upload-size"
+	FlagLink       FlagKey = "link"
+	FlagForce      FlagKey = "force"
+	FlagFormat     FlagKey = "format"
+	FlagNoBuild    FlagKey = "no-build"
+	FlagGdb        FlagKey = "gdb"
+	FlagValgrind   FlagKey = "valgrind"
+	FlagWrap       FlagKey = "wrap"
+	FlagStaging    FlagKey = "staging"
+	FlagExports    FlagKey = "exports"
+	FlagAll        FlagKey = "all"
+	FlagFrom       FlagKey = "from"
+	FlagTo         FlagKey = "to"
+	FlagStatus     FlagKey = "status"
+	FlagRdeps      FlagKey = "rdeps"
+	FlagDepsOnly   FlagKey = "deps-only"
+	FlagNoDeps     FlagKey = "no-deps"
+	FlagVariant    FlagKey = "variant"
+	FlagSearchDir  FlagKey = "search-dir"
+	FlagLauncher   FlagKey = "launcher"
+	FlagInterval   FlagKey = "interval"
+	FlagDebounce   FlagKey = "debounce"
+
+	FlagCC            FlagKey = "cc"
+	FlagCXX           FlagKey = "cxx"
+	FlagCFlags        FlagKey = "cflags"
+	FlagCXXFlags      FlagKey = "cxxflags"
+	FlagLDFlags       FlagKey = "ldflags"
+	FlagLinkerType    FlagKey = "linker"
+	FlagSystem        FlagKey = "system"
+	FlagArch          FlagKey = "arch"
+	FlagHost          FlagKey = "host"
+	FlagToolchainFile FlagKey = "toolchain-file"
 )
 
 type FlagKey string
@@ -26,7 +67,7 @@ var (
 
 	ConfigFlag = cli.NewStringFlag("c", "config", cli.FlagKey(FlagConfig), "build configuration to use (e.g., Debug, Release), comma separated")
 
-	TargetFlag = cli.NewStringFlagFromArgument("t", "target", cli.FlagKey(FlagTarget), "specific target to build")
+	TargetFlag = cli.NewStringFlagFromArgument("t", "target", cli.FlagKey(FlagTarget), "targets to use: names, globs (lib*), tags (@tools) and exclusions (!foo), comma separated")
 
 	SourceFlag = cli.NewStringFlagFromArgument("s", "source", cli.FlagKey(FlagSource), "specific source to operate on")
 
@@ -45,4 +86,84 @@ var (
 	NoSetupFlag = cli.NewBoolFlag("", "no-setup", cli.FlagKey(FlagNoSetup), "don't run setup after downloading or cloning")
 
 	HelpFlag = cli.NewBoolFlag("h", "help", cli.FlagKey(FlagHelp), "show this help message")
+
+	ResumeFlag = cli.NewBoolFlag("", "resume", cli.FlagKey(FlagResume), "skip targets that succeeded in the previous build")
+
+	OnlyFailedFlag = cli.NewBoolFlag("", "only-failed", cli.FlagKey(FlagOnlyFailed), "rebuild only targets that failed in the previous build, plus their dependents")
+
+	MaxAgeFlag = cli.NewStringFlag("", "max-age", cli.FlagKey(FlagMaxAge), "remove cache entries not used within this age (e.g. 30d, 12h)")
+
+	MaxSizeFlag = cli.NewStringFlag("", "max-size", cli.FlagKey(FlagMaxSize), "remove least recently used cache entries until the cache fits this size (e.g. 10G)")
+
+	ListenFlag = cli.NewStringFlag("l", "listen", cli.FlagKey(FlagListen), "address to listen on (default :8080)")
+
+	CacheDirFlag = cli.NewStringFlag("", "cache-dir", cli.FlagKey(FlagCacheDir), "cache directory to serve (default: the workspace or user cache directory)")
+
+	ReadOnlyFlag = cli.NewBoolFlag("", "read-only", cli.FlagKey(FlagReadOnly), "reject uploads")
+
+	MaxUploadSizeFlag = cli.NewStringFlag("", "max-upload-size", cli.FlagKey(FlagMaxUpload), "largest file accepted for upload (default 4G)")
+
+	EnvFormatFlag = cli.NewStringFlag("", "format", cli.FlagKey(FlagFormat), "output format: bash, fish or json")
+
+	ForceFlag = cli.NewBoolFlag("f", "force", cli.FlagKey(FlagForce), "overwrite files that were not generated by cbuild")
+
+	LinkFlag = cli.NewBoolFlag("", "link", cli.FlagKey(FlagLink), "also symlink each target's compile_commands.json into the root of its source")
+
+	NoBuildFlag = cli.NewBoolFlag("", "no-build", cli.FlagKey(FlagNoBuild), "run the existing build without building the target first")
+
+	GdbFlag = cli.NewBoolFlag("", "gdb", cli.FlagKey(FlagGdb), "run the executable under gdb")
+
+	ValgrindFlag = cli.NewBoolFlag("", "valgrind", cli.FlagKey(FlagValgrind), "run the executable under valgrind")
+
+	VariantFlag = cli.NewStringFlag("V", "variant", cli.FlagKey(FlagVariant), "build variants to use, comma separated (default: all)")
+
+	IntervalFlag = cli.NewStringFlag("", "interval", cli.FlagKey(FlagInterval), "how often to scan the sources for changes (default 1s)")
+
+	DebounceFlag = cli.NewStringFlag("", "debounce", cli.FlagKey(FlagDebounce), "how long the sources must stay unchanged before rebuilding (default 500ms)")
+
+	RdepsFlag = cli.NewBoolFlag("", "rdeps", cli.FlagKey(FlagRdeps), "also build every target that depends on the target")
+
+	DepsOnlyFlag = cli.NewBoolFlag("", "deps-only", cli.FlagKey(FlagDepsOnly), "build only the dependencies of the selected targets")
+
+	NoDepsFlag = cli.NewBoolFlag("", "no-deps", cli.FlagKey(FlagNoDeps), "build only the selected targets, not their dependencies")
+
+	GraphFormatFlag = cli.NewStringFlag("", "format", cli.FlagKey(FlagFormat), "output format: dot, mermaid or json")
+
+	FromFlag = cli.NewStringFlag("", "from", cli.FlagKey(FlagFrom), "only include these targets and their dependencies")
+
+	ToFlag = cli.NewStringFlag("", "to", cli.FlagKey(FlagTo), "only include these targets and the targets that depend on them")
+
+	StatusFlag = cli.NewBoolFlag("", "status", cli.FlagKey(FlagStatus), "colour targets by the result of the last build")
+
+	StagingFlag = cli.NewBoolFlag("", "staging", cli.FlagKey(FlagStaging), "also remove staging directories")
+
+	ExportsFlag = cli.NewBoolFlag("", "exports", cli.FlagKey(FlagExports), "also remove export directories")
+
+	AllFlag = cli.NewBoolFlag("", "all", cli.FlagKey(FlagAll), "remove build trees, staging and export directories and generated toolchain files")
+
+	SearchDirFlag = cli.NewStringFlag("", "search-dir", cli.FlagKey(FlagSearchDir), "extra directories to search for compilers, comma separated")
+
+	LauncherFlag = cli.NewBoolFlag("", "launcher", cli.FlagKey(FlagLauncher), "detect ccache or sccache and use it as the compiler launcher")
+
+	CCFlag = cli.NewStringFlag("", "cc", cli.FlagKey(FlagCC), "C compiler")
+
+	CXXFlag = cli.NewStringFlag("", "cxx", cli.FlagKey(FlagCXX), "C++ compiler")
+
+	CFlagsFlag = cli.NewStringFlag("", "cflags", cli.FlagKey(FlagCFlags), "extra C compiler flags, space separated")
+
+	CXXFlagsFlag = cli.NewStringFlag("", "cxxflags", cli.FlagKey(FlagCXXFlags), "extra C++ compiler flags, space separated")
+
+	LDFlagsFlag = cli.NewStringFlag("", "ldflags", cli.FlagKey(FlagLDFlags), "extra linker flags, space separated")
+
+	LinkerTypeFlag = cli.NewStringFlag("", "linker", cli.FlagKey(FlagLinkerType), "linker to use: lld, mold, gold or bfd")
+
+	SystemFlag = cli.NewStringFlag("", "system", cli.FlagKey(FlagSystem), "target system (default: the host system)")
+
+	ArchFlag = cli.NewStringFlag("", "arch", cli.FlagKey(FlagArch), "target processor (default: the host processor)")
+
+	HostFlag = cli.NewStringFlag("", "host", cli.FlagKey(FlagHost), "host key the toolchain applies to (default: this host, e.g. host-linux-x64)")
+
+	ToolchainFileFlag = cli.NewStringFlag("", "toolchain-file", cli.FlagKey(FlagToolchainFile), "use an existing CMake toolchain file instead of generating one")
+
+	WrapFlag = cli.NewStringFlag("", "wrap", cli.FlagKey(FlagWrap), "command to run the executable under, e.g. \"perf record\"")
 )
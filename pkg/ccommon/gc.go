@@ -0,0 +1,170 @@
+package ccommon
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GCResult lists the directories removed by GarbageCollect and the space they used.
+type GCResult struct {
+	Removed   []string
+	Reclaimed int64
+}
+
+// outputTree describes the layout of one of the workspace output directories,
+// e.g. buildspaces/<toolchain>/<target>/<config>.
+type outputTree struct {
+	root   string
+	levels []string
+}
+
+// GarbageCollect removes the buildspace, staging and export directories that
+// do not belong to any current combination of target, toolchain and
+// configuration, along with generated toolchain files of removed toolchains.
+func (w *WorkspaceContext) GarbageCollect(ctx context.Context, dryRun bool) (*GCResult, error) {
+	toolchains, err := w.ListToolchains(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing toolchains: %w", err)
+	}
+
+	valid := map[string]map[string]bool{
+		"toolchain": make(map[string]bool),
+		"target":    make(map[string]bool),
+		"config":    make(map[string]bool),
+	}
+	for _, tc := range toolchains {
+		valid["toolchain"][tc] = true
+	}
+	for _, t := range w.ListTargets(ctx) {
+		valid["target"][t] = true
+	}
+	for _, cfg := range w.Config.Configurations {
+		variants, err := w.ResolveVariants("")
+		if err != nil {
+			return nil, err
+		}
+		for _, variant := range variants {
+			valid["config"][TargetBuildParameters{BuildType: cfg, Variant: variant}.ConfigDirName()] = true
+		}
+	}
+
+	staged := make(map[string]bool)
+	for name, t := range w.Config.Targets {
+		if t != nil && t.Staged != nil && *t.Staged {
+			staged[name] = true
+		}
+	}
+
+	trees := []outputTree{
+		{root: "buildspaces", levels: []string{"toolchain", "target", "config"}},
+		{root: "staging", levels: []string{"toolchain", "config", "target"}},
+		{root: "exports", levels: []string{"toolchain", "target", "config"}},
+	}
+
+	result := &GCResult{}
+	for _, tree := range trees {
+		root := filepath.Join(w.WorkspacePath, tree.root)
+		err := w.collectTree(root, tree, 0, func(level string, name string) bool {
+			if tree.root == "staging" && level == "target" {
+				return staged[name]
+			}
+			return valid[level][name]
+		}, dryRun, result)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if w.State != nil && w.State.Results != nil && !dryRun {
+		w.pruneBuildState(valid)
+		err = w.SaveBuildState(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// collectTree removes the directories under dir, at the given depth of tree,
+// whose name keep rejects. Files are left alone, except for generated
+// toolchain files in the directories of removed toolchains, which go with
+// their directory.
+func (w *WorkspaceContext) collectTree(dir string, tree outputTree, depth int, keep func(level string, name string) bool, dryRun bool, result *GCResult) error {
+	if depth >= len(tree.levels) {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+
+		if keep(tree.levels[depth], entry.Name()) {
+			err = w.collectTree(path, tree, depth+1, keep, dryRun, result)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		size := directorySize(path)
+		if dryRun {
+			fmt.Printf("dry-run: would delete %s\n", path)
+		} else {
+			err = os.RemoveAll(path)
+			if err != nil {
+				return fmt.Errorf("failed to delete %s: %w", path, err)
+			}
+		}
+		result.Removed = append(result.Removed, path)
+		result.Reclaimed += size
+	}
+	return nil
+}
+
+// pruneBuildState drops recorded results of toolchains, configurations and
+// targets that no longer exist.
+func (w *WorkspaceContext) pruneBuildState(valid map[string]map[string]bool) {
+	for key, results := range w.State.Results {
+		tc, cfg, _ := strings.Cut(key, "/")
+		if !valid["toolchain"][tc] || !valid["config"][cfg] {
+			delete(w.State.Results, key)
+			continue
+		}
+		for name := range results {
+			if !valid["target"][name] {
+				delete(results, name)
+			}
+		}
+	}
+}
+
+func directorySize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
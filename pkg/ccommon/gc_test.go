@@ -0,0 +1,150 @@
+package ccommon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// setupGCWorkspace creates a workspace with a current and a stale directory
+// for every level of the buildspaces, staging and exports trees. It returns
+// the directories gc must keep and the ones it must remove.
+func setupGCWorkspace(t *testing.T) (*WorkspaceContext, []string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	staged := true
+	w := &WorkspaceContext{
+		WorkspacePath: t.TempDir(),
+		Config: WorkspaceConfig{
+			Configurations: []string{"Debug"},
+			Variants:       map[string]*Variant{"shared": {}},
+			Targets: map[string]*TargetConfiguration{
+				"lib": {Staged: &staged},
+				"app": nil,
+			},
+		},
+		State: &BuildState{},
+	}
+	writeTestToolchain(t, w, "gcc", &Toolchain{})
+
+	current := TargetBuildParameters{Toolchain: "gcc", BuildType: "Debug", Variant: "shared"}
+	stale := []TargetBuildParameters{
+		{Toolchain: "old", BuildType: "Debug", Variant: "shared"},
+		{Toolchain: "gcc", BuildType: "Release", Variant: "shared"},
+		{Toolchain: "gcc", BuildType: "Debug", Variant: "static"},
+		{Toolchain: "gcc", BuildType: "Debug"},
+	}
+
+	paths := func(name string, bp TargetBuildParameters, withStaging bool) []string {
+		mod := &TargetContext{Name: name}
+		build, _ := mod.CMakeBuildPath(ctx, w, bp)
+		export, _ := mod.CMakeExportPath(ctx, w, bp)
+		result := []string{build, export}
+		if withStaging {
+			staging, _ := mod.CMakeStagingPath(ctx, w, bp)
+			result = append(result, staging)
+		}
+		return result
+	}
+
+	var keep, remove []string
+	keep = append(keep, paths("lib", current, true)...)
+	keep = append(keep, paths("app", current, false)...)
+	for _, bp := range stale {
+		remove = append(remove, paths("lib", bp, true)...)
+	}
+	remove = append(remove, paths("gone", current, false)...)
+	// Staging directories of targets that are not staged are removed too.
+	appStaging, _ := (&TargetContext{Name: "app"}).CMakeStagingPath(ctx, w, current)
+	remove = append(remove, appStaging)
+
+	for _, dir := range append(append([]string{}, keep...), remove...) {
+		writeTestFile(t, filepath.Join(dir, "file"), "x")
+	}
+	writeTestFile(t, filepath.Join(w.WorkspacePath, "buildspaces", "gcc", "generated_toolchain.cmake"), "")
+
+	w.State.SetStatus(current, "lib", BuildStatusSucceeded)
+	w.State.SetStatus(current, "gone", BuildStatusFailed)
+	w.State.SetStatus(stale[0], "lib", BuildStatusSucceeded)
+	if err := w.SaveBuildState(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return w, keep, remove
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestGarbageCollect(t *testing.T) {
+	w, keep, remove := setupGCWorkspace(t)
+
+	result, err := w.GarbageCollect(context.Background(), false)
+	if err != nil {
+		t.Fatalf("GarbageCollect() error = %v", err)
+	}
+
+	for _, dir := range keep {
+		if !exists(dir) {
+			t.Errorf("current directory %s was removed", dir)
+		}
+	}
+	for _, dir := range remove {
+		if exists(dir) {
+			t.Errorf("stale directory %s was kept", dir)
+		}
+	}
+	for _, file := range []string{
+		w.BuildStatePath(),
+		filepath.Join(w.WorkspacePath, "buildspaces", "gcc", "generated_toolchain.cmake"),
+	} {
+		if !exists(file) {
+			t.Errorf("%s was removed", file)
+		}
+	}
+	if result.Reclaimed == 0 {
+		t.Error("GarbageCollect() reclaimed nothing")
+	}
+
+	var keys []string
+	for key, results := range w.State.Results {
+		for name := range results {
+			keys = append(keys, key+" "+name)
+		}
+	}
+	sort.Strings(keys)
+	want := TargetBuildParameters{Toolchain: "gcc", BuildType: "Debug", Variant: "shared"}
+	if len(keys) != 1 || keys[0] != buildStateKey(want)+" lib" {
+		t.Errorf("pruned build state = %v, want only lib in %s", keys, buildStateKey(want))
+	}
+}
+
+func TestGarbageCollectDryRun(t *testing.T) {
+	w, keep, remove := setupGCWorkspace(t)
+	before, err := os.ReadFile(w.BuildStatePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := w.GarbageCollect(context.Background(), true)
+	if err != nil {
+		t.Fatalf("GarbageCollect() error = %v", err)
+	}
+
+	for _, dir := range append(keep, remove...) {
+		if !exists(dir) {
+			t.Errorf("dry run removed %s", dir)
+		}
+	}
+	if len(result.Removed) == 0 {
+		t.Error("dry run reported nothing to remove")
+	}
+	after, err := os.ReadFile(w.BuildStatePath())
+	if err != nil || string(after) != string(before) {
+		t.Errorf("dry run changed the build state")
+	}
+}
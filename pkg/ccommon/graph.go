@@ -0,0 +1,173 @@
+package ccommon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DependencyTargetName returns the target name of a dependency entry, stripping
+// any component suffix (e.g. "dep/sub" -> "dep").
+func DependencyTargetName(dep string) string {
+	parts := strings.SplitN(dep, "/", 2)
+	return parts[0]
+}
+
+// TargetDependencies returns the names of the targets that the given target
+// directly depends on, without component suffixes or duplicates.
+func (w *WorkspaceContext) TargetDependencies(ctx context.Context, targetName string) ([]string, error) {
+	target, ok := w.Config.Targets[targetName]
+	if !ok {
+		return nil, fmt.Errorf("target %s not found in workspace", targetName)
+	}
+	if target == nil {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool)
+	var deps []string
+	for _, dep := range target.Depends {
+		name := DependencyTargetName(dep)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		deps = append(deps, name)
+	}
+	return deps, nil
+}
+
+// TargetBuildOrder returns the given targets and all of their transitive
+// dependencies, ordered so that every target comes after its dependencies.
+func (w *WorkspaceContext) TargetBuildOrder(ctx context.Context, roots []string) ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	state := make(map[string]int)
+	var order []string
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle detected: %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+
+		deps, err := w.TargetDependencies(ctx, name)
+		if err != nil {
+			return err
+		}
+		for _, dep := range deps {
+			err = visit(dep, append(path, name))
+			if err != nil {
+				return err
+			}
+		}
+
+		state[name] = done
+		order = append(order, name)
+		return nil
+	}
+
+	for _, root := range roots {
+		err := visit(root, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// TargetDependents returns the names of the targets that transitively depend
+// on any of the given targets, sorted by name. The targets themselves are not
+// included.
+func (w *WorkspaceContext) TargetDependents(ctx context.Context, roots []string) ([]string, error) {
+	reverse := make(map[string][]string)
+	for _, name := range w.ListTargets(ctx) {
+		deps, err := w.TargetDependencies(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range deps {
+			reverse[dep] = append(reverse[dep], name)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		if _, ok := w.Config.Targets[root]; !ok {
+			return nil, fmt.Errorf("target %s not found in workspace", root)
+		}
+		seen[root] = true
+	}
+
+	queue := append([]string{}, roots...)
+	var dependents []string
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, dependent := range reverse[name] {
+			if seen[dependent] {
+				continue
+			}
+			seen[dependent] = true
+			dependents = append(dependents, dependent)
+			queue = append(queue, dependent)
+		}
+	}
+
+	sort.Strings(dependents)
+	return dependents, nil
+}
+
+// DependencyPaths returns every dependency path from one target to another,
+// each starting with from and ending with to.
+func (w *WorkspaceContext) DependencyPaths(ctx context.Context, from string, to string) ([][]string, error) {
+	if _, ok := w.Config.Targets[to]; !ok {
+		return nil, fmt.Errorf("target %s not found in workspace", to)
+	}
+
+	var paths [][]string
+	onPath := make(map[string]bool)
+
+	var walk func(name string, path []string) error
+	walk = func(name string, path []string) error {
+		if onPath[name] {
+			return fmt.Errorf("dependency cycle detected: %s", strings.Join(append(path, name), " -> "))
+		}
+		path = append(path, name)
+		if name == to {
+			paths = append(paths, append([]string{}, path...))
+			return nil
+		}
+
+		onPath[name] = true
+		defer delete(onPath, name)
+
+		deps, err := w.TargetDependencies(ctx, name)
+		if err != nil {
+			return err
+		}
+		for _, dep := range deps {
+			err = walk(dep, path)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := walk(from, nil)
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
@@ -0,0 +1,230 @@
+package ccommon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/cmake"
+)
+
+// CompilerLauncherNone disables a compiler launcher set in the workspace for
+// a single toolchain.
+const CompilerLauncherNone = "none"
+
+// compilerLaunchers are the launchers DetectToolchains looks for, in order of
+// preference.
+var compilerLaunchers = []string{"ccache", "sccache"}
+
+// CompilerLauncher returns the compiler launcher of a toolchain, falling back
+// to the workspace launcher, or "" for none.
+func (w *WorkspaceContext) CompilerLauncher(tc *Toolchain) string {
+	launcher := w.Config.CompilerLauncher
+	if tc != nil && tc.CompilerLauncher != "" {
+		launcher = tc.CompilerLauncher
+	}
+	if launcher == CompilerLauncherNone {
+		return ""
+	}
+	return launcher
+}
+
+// DetectCompilerLauncher returns the first of ccache and sccache found in
+// PATH, or "" if neither is installed.
+func DetectCompilerLauncher() string {
+	for _, launcher := range compilerLaunchers {
+		if _, err := exec.LookPath(launcher); err == nil {
+			return launcher
+		}
+	}
+	return ""
+}
+
+// launcherKind returns "ccache" or "sccache" for a launcher given by name or
+// path, or "" for other launchers.
+func launcherKind(launcher string) string {
+	name := strings.TrimSuffix(filepath.Base(launcher), ".exe")
+	for _, kind := range compilerLaunchers {
+		if name == kind {
+			return kind
+		}
+	}
+	return ""
+}
+
+// launcherDirVar returns the environment variable holding the cache directory
+// of a launcher.
+func launcherDirVar(launcher string) string {
+	switch launcherKind(launcher) {
+	case "ccache":
+		return "CCACHE_DIR"
+	case "sccache":
+		return "SCCACHE_DIR"
+	}
+	return ""
+}
+
+// CompilerLauncherEnvironment returns the environment the build runs with so
+// that the launcher keeps its cache in the workspace, in cache/<launcher>. A
+// cache directory already set in the environment is left alone.
+//
+// sccache reads SCCACHE_DIR when its server starts, so a server that is
+// already running keeps its own directory. It is not restarted here, as other
+// builds may be using it.
+func (w *WorkspaceContext) CompilerLauncherEnvironment(launcher string) (RuntimeEnvironment, error) {
+	name := launcherDirVar(launcher)
+	if name == "" || os.Getenv(name) != "" {
+		return nil, nil
+	}
+
+	workspacePath, err := w.absWorkspacePath()
+	if err != nil {
+		return nil, err
+	}
+	return RuntimeEnvironment{{Name: name, Paths: []string{filepath.Join(workspacePath, "cache", launcherKind(launcher))}}}, nil
+}
+
+// CompilerLauncherArgs returns the cmake configure arguments that set the
+// compiler launcher. Generated toolchain files set the launcher themselves.
+func (w *WorkspaceContext) CompilerLauncherArgs(ctx context.Context, bp TargetBuildParameters) ([]string, error) {
+	tc, _, err := w.LoadToolchain(ctx, bp.Toolchain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load toolchain: %w", err)
+	}
+	if tcf, ok := tc.CMakeToolchain[HostToolchainKey()]; ok && tcf.Generate != nil {
+		return nil, nil
+	}
+	return cmake.CompilerLauncherCacheArgs(w.CompilerLauncher(tc)), nil
+}
+
+// buildLauncher returns the compiler launcher of the toolchain being built and
+// the environment it runs with.
+func (w *WorkspaceContext) buildLauncher(ctx context.Context, bp TargetBuildParameters) (string, RuntimeEnvironment, error) {
+	tc, _, err := w.LoadToolchain(ctx, bp.Toolchain)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load toolchain: %w", err)
+	}
+	launcher := w.CompilerLauncher(tc)
+	env, err := w.CompilerLauncherEnvironment(launcher)
+	if err != nil {
+		return "", nil, err
+	}
+	return launcher, env, nil
+}
+
+// reportLauncherStats prints the cache hit rate of the compilations since
+// before.
+func reportLauncherStats(ctx context.Context, launcher string, env RuntimeEnvironment, before LauncherStats) {
+	after, err := ReadLauncherStats(ctx, launcher, env)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return
+	}
+	stats := after.Sub(before)
+	if stats.Hits+stats.Misses == 0 {
+		return
+	}
+	fmt.Printf("Compiler cache (%s): %d hits, %d misses, %.1f%% hit rate\n", launcherKind(launcher), stats.Hits, stats.Misses, stats.HitRate())
+}
+
+// LauncherStats counts the compilations a launcher found in its cache.
+type LauncherStats struct {
+	Hits   int64
+	Misses int64
+}
+
+// Sub returns the compilations counted since before.
+func (s LauncherStats) Sub(before LauncherStats) LauncherStats {
+	return LauncherStats{Hits: s.Hits - before.Hits, Misses: s.Misses - before.Misses}
+}
+
+// HitRate returns the percentage of compilations that were cache hits.
+func (s LauncherStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) * 100 / float64(s.Hits+s.Misses)
+}
+
+// ReadLauncherStats reads the statistics of a ccache or sccache launcher,
+// with env being the environment the build runs with.
+func ReadLauncherStats(ctx context.Context, launcher string, env RuntimeEnvironment) (LauncherStats, error) {
+	var args []string
+	switch launcherKind(launcher) {
+	case "ccache":
+		args = []string{"--print-stats"}
+	case "sccache":
+		args = []string{"--show-stats", "--stats-format=json"}
+	default:
+		return LauncherStats{}, fmt.Errorf("no statistics for compiler launcher %s", launcher)
+	}
+
+	cmd := exec.CommandContext(ctx, launcher, args...)
+	cmd.Env = env.Environ(os.Environ())
+	out, err := cmd.Output()
+	if err != nil {
+		return LauncherStats{}, fmt.Errorf("failed to read %s statistics: %w", launcher, err)
+	}
+
+	if launcherKind(launcher) == "ccache" {
+		return parseCCacheStats(out)
+	}
+	return parseSCCacheStats(out)
+}
+
+// parseCCacheStats parses the tab separated output of ccache --print-stats.
+func parseCCacheStats(out []byte) (LauncherStats, error) {
+	var stats LauncherStats
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "direct_cache_hit", "preprocessed_cache_hit":
+			stats.Hits += n
+		case "cache_miss":
+			stats.Misses += n
+		}
+	}
+	return stats, scanner.Err()
+}
+
+// parseSCCacheStats parses the output of sccache --show-stats --stats-format=json.
+func parseSCCacheStats(out []byte) (LauncherStats, error) {
+	var result struct {
+		Stats struct {
+			CacheHits struct {
+				Counts map[string]int64 `json:"counts"`
+			} `json:"cache_hits"`
+			CacheMisses struct {
+				Counts map[string]int64 `json:"counts"`
+			} `json:"cache_misses"`
+		} `json:"stats"`
+	}
+	err := json.Unmarshal(out, &result)
+	if err != nil {
+		return LauncherStats{}, fmt.Errorf("failed to parse sccache statistics: %w", err)
+	}
+
+	var stats LauncherStats
+	for _, n := range result.Stats.CacheHits.Counts {
+		stats.Hits += n
+	}
+	for _, n := range result.Stats.CacheMisses.Counts {
+		stats.Misses += n
+	}
+	return stats, nil
+}
@@ -0,0 +1,111 @@
+package ccommon
+
+import (
+	"testing"
+)
+
+// ccacheStats is the output of ccache 4.9 --print-stats, tab separated.
+const ccacheStats = `stats_updated_timestamp	1718000000
+stats_zeroed_timestamp	1717000000
+autoconf_test	0
+bad_compiler_arguments	2
+bad_input_file	0
+bad_output_file	0
+cache_miss	12
+cache_size_kibibyte	20480
+called_for_link	3
+called_for_preprocessing	0
+compile_failed	1
+compiler_check_failed	0
+compiler_produced_empty_output	0
+compiler_produced_no_output	0
+compiler_produced_stdout	0
+could_not_find_compiler	0
+could_not_use_modules	0
+could_not_use_precompiled_header	0
+direct_cache_hit	30
+direct_cache_miss	14
+disabled	0
+error_hashing_extra_file	0
+files_in_cache	84
+internal_error	0
+local_storage_hit	32
+local_storage_miss	12
+local_storage_read_hit	60
+local_storage_read_miss	28
+local_storage_write	24
+missing_cache_file	0
+multiple_source_files	0
+no_input_file	0
+output_to_stdout	0
+preprocessed_cache_hit	2
+preprocessed_cache_miss	12
+preprocessor_error	0
+recache	0
+remote_storage_error	0
+remote_storage_hit	0
+remote_storage_miss	0
+remote_storage_read_hit	0
+remote_storage_read_miss	0
+remote_storage_timeout	0
+remote_storage_write	0
+unsupported_code_directive	0
+unsupported_compiler_option	0
+unsupported_environment_variable	0
+unsupported_source_language	0
+`
+
+// sccacheStats is the output of sccache 0.8 --show-stats --stats-format=json.
+const sccacheStats = `{"stats":{"compile_requests":44,"requests_unsupported_compiler":0,"requests_not_compile":2,"requests_not_cacheable":1,"requests_executed":41,"cache_errors":{"counts":{},"adv_counts":{}},"cache_hits":{"counts":{"C/C++":25,"CUDA":3},"adv_counts":{"c [gcc]":10,"cpp [gcc]":15,"cuda [nvcc]":3}},"cache_misses":{"counts":{"C/C++":13},"adv_counts":{"c [gcc]":4,"cpp [gcc]":9}},"cache_timeouts":0,"cache_read_errors":0,"non_cacheable_compilations":0,"forced_recaches":0,"cache_write_errors":0,"cache_writes":13,"cache_write_duration":{"secs":0,"nanos":52000000},"cache_read_hit_duration":{"secs":0,"nanos":31000000},"compilations":13,"compiler_write_duration":{"secs":0,"nanos":0},"compilation_time":{"secs":21,"nanos":400000000},"not_cached":{"-E":1},"dist_compiles":{},"dist_errors":0},"cache_location":"Local disk: \"/home/user/work/cache/sccache\"","use_preprocessor_cache_mode":true,"cache_size":73400320,"max_cache_size":10737418240}`
+
+func TestParseCCacheStats(t *testing.T) {
+	got, err := parseCCacheStats([]byte(ccacheStats))
+	if err != nil {
+		t.Fatalf("parseCCacheStats() error = %v", err)
+	}
+	if want := (LauncherStats{Hits: 32, Misses: 12}); got != want {
+		t.Errorf("parseCCacheStats() = %+v, want %+v", got, want)
+	}
+
+	// Output without tab separated counters, such as an error, counts nothing.
+	got, err = parseCCacheStats([]byte("ccache: invalid option -- 'print-stats'\n"))
+	if err != nil || got != (LauncherStats{}) {
+		t.Errorf("parseCCacheStats(unknown output) = %+v, %v, want no statistics", got, err)
+	}
+}
+
+func TestParseSCCacheStats(t *testing.T) {
+	got, err := parseSCCacheStats([]byte(sccacheStats))
+	if err != nil {
+		t.Fatalf("parseSCCacheStats() error = %v", err)
+	}
+	if want := (LauncherStats{Hits: 28, Misses: 13}); got != want {
+		t.Errorf("parseSCCacheStats() = %+v, want %+v", got, want)
+	}
+
+	// A fresh server has empty counts.
+	got, err = parseSCCacheStats([]byte(`{"stats":{"cache_hits":{"counts":{}},"cache_misses":{"counts":{}}}}`))
+	if err != nil || got != (LauncherStats{}) {
+		t.Errorf("parseSCCacheStats(empty) = %+v, %v, want no statistics", got, err)
+	}
+
+	if _, err := parseSCCacheStats([]byte("Compile requests 44\n")); err == nil {
+		t.Errorf("parseSCCacheStats(text output) = nil error, want an error")
+	}
+}
+
+func TestLauncherStats(t *testing.T) {
+	before := LauncherStats{Hits: 10, Misses: 5}
+	after := LauncherStats{Hits: 40, Misses: 15}
+
+	got := after.Sub(before)
+	if got != (LauncherStats{Hits: 30, Misses: 10}) {
+		t.Errorf("Sub() = %+v, want 30 hits and 10 misses", got)
+	}
+	if rate := got.HitRate(); rate != 75 {
+		t.Errorf("HitRate() = %v, want 75", rate)
+	}
+	if rate := (LauncherStats{}).HitRate(); rate != 0 {
+		t.Errorf("HitRate() of no compilations = %v, want 0", rate)
+	}
+}
@@ -0,0 +1,152 @@
+package ccommon
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/host"
+	"gitlab.com/rpnx/cbuild-go/pkg/system"
+)
+
+// IsCross reports whether the toolchain targets a different platform or
+// processor than the host.
+func (tc *Toolchain) IsCross() bool {
+	if tc.TargetSystem != system.PlatformUnknown && tc.TargetSystem != host.DetectHostPlatform() {
+		return true
+	}
+	if tc.TargetArch != system.ProcessorUnknown && tc.TargetArch != host.DetectHostProcessor() {
+		return true
+	}
+	return false
+}
+
+// Sysroot returns the absolute sysroot of the toolchain's generated toolchain
+// file on this host, or "" if it has none.
+func (w *WorkspaceContext) Sysroot(tc *Toolchain) (string, error) {
+	tcf, ok := tc.CMakeToolchain[HostToolchainKey()]
+	if !ok || tcf.Generate == nil || tcf.Generate.Sysroot == "" {
+		return "", nil
+	}
+	sysroot := tcf.Generate.Sysroot
+	if !filepath.IsAbs(sysroot) {
+		sysroot = filepath.Join(w.WorkspacePath, sysroot)
+	}
+	return filepath.Abs(sysroot)
+}
+
+// SysrootPkgConfigDirs returns the pkg-config directories of a sysroot,
+// including the multiarch directory of the target triple if it is known.
+func SysrootPkgConfigDirs(sysroot string, triple string) []string {
+	dirs := []string{filepath.Join(sysroot, "usr", "lib", "pkgconfig")}
+	if triple != "" {
+		dirs = append(dirs, filepath.Join(sysroot, "usr", "lib", triple, "pkgconfig"))
+	}
+	return append(dirs, filepath.Join(sysroot, "usr", "share", "pkgconfig"))
+}
+
+// PkgConfigEnvironment returns the pkg-config variables a target should be
+// configured with, so that pkg_check_modules and other pkg-config consumers
+// find the .pc files of its staged dependencies. Cross toolchains also replace
+// PKG_CONFIG_LIBDIR with the staged and sysroot directories, which keeps
+// pkg-config from falling back to the host's own .pc files, and set
+// PKG_CONFIG_SYSROOT_DIR to the sysroot. For native toolchains it returns nil
+// if no dependency has pkg-config files staged.
+func (w *WorkspaceContext) PkgConfigEnvironment(ctx context.Context, t *TargetContext, bp TargetBuildParameters) (RuntimeEnvironment, error) {
+	order, err := w.TargetBuildOrder(ctx, []string{t.Name})
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	// Nearer dependencies come later in build order and take precedence.
+	for i := len(order) - 1; i >= 0; i-- {
+		if order[i] == t.Name {
+			continue
+		}
+		dep, err := w.GetTarget(ctx, order[i])
+		if err != nil {
+			return nil, err
+		}
+		if dep.Config.Staged == nil || !*dep.Config.Staged {
+			continue
+		}
+
+		stagingPath, err := dep.CMakeStagingPath(ctx, w, bp)
+		if err != nil {
+			return nil, err
+		}
+		stagingPath, err = filepath.Abs(stagingPath)
+		if err != nil {
+			return nil, err
+		}
+		dirs = appendExisting(dirs, StagingPkgConfigDirs(stagingPath)...)
+	}
+
+	tc, _, err := w.LoadToolchain(ctx, bp.Toolchain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load toolchain: %w", err)
+	}
+
+	var env RuntimeEnvironment
+	if len(dirs) != 0 {
+		env = append(env, EnvVar{Name: "PKG_CONFIG_PATH", Paths: dirs})
+	}
+	if !tc.IsCross() {
+		return env, nil
+	}
+
+	sysroot, err := w.Sysroot(tc)
+	if err != nil {
+		return nil, err
+	}
+	libDirs := append([]string{}, dirs...)
+	if sysroot != "" {
+		triple := tc.CMakeToolchain[HostToolchainKey()].Generate.TargetTriple
+		libDirs = appendExisting(libDirs, SysrootPkgConfigDirs(sysroot, triple)...)
+	}
+	env = append(env, EnvVar{Name: "PKG_CONFIG_LIBDIR", Paths: libDirs, Replace: true})
+	if sysroot != "" {
+		env = append(env, EnvVar{Name: "PKG_CONFIG_SYSROOT_DIR", Paths: []string{sysroot}, Replace: true})
+	}
+	return env, nil
+}
+
+// CheckPkgConfigFiles returns a warning for every line of a .pc file installed
+// under stagingPath that refers to buildspacesPath. Such files point into a
+// build tree, which breaks consumers once the tree is cleaned or the staging
+// directory is restored from the cache.
+func CheckPkgConfigFiles(stagingPath string, buildspacesPath string) ([]string, error) {
+	var warnings []string
+
+	for _, dir := range StagingPkgConfigDirs(stagingPath) {
+		files, err := filepath.Glob(filepath.Join(dir, "*.pc"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			f, err := os.Open(file)
+			if err != nil {
+				return nil, err
+			}
+
+			scanner := bufio.NewScanner(f)
+			for line := 1; scanner.Scan(); line++ {
+				if strings.Contains(scanner.Text(), buildspacesPath) {
+					warnings = append(warnings, fmt.Sprintf("%s:%d refers to the build tree: %s", file, line, strings.TrimSpace(scanner.Text())))
+				}
+			}
+			err = scanner.Err()
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", file, err)
+			}
+		}
+	}
+
+	return warnings, nil
+}
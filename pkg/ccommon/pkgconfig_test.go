@@ -0,0 +1,141 @@
+package ccommon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/host"
+	"gitlab.com/rpnx/cbuild-go/pkg/system"
+	"gopkg.in/yaml.v3"
+)
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestToolchain(t *testing.T, w *WorkspaceContext, name string, tc *Toolchain) {
+	t.Helper()
+	data, err := yaml.Marshal(tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(w.WorkspacePath, "toolchains", name, "toolchain.yml"), string(data))
+}
+
+// crossArch returns a processor other than the host's.
+func crossArch() system.Processor {
+	if host.DetectHostProcessor() == system.ProcessorArm64 {
+		return system.ProcessorX64
+	}
+	return system.ProcessorArm64
+}
+
+func TestPkgConfigEnvironment(t *testing.T) {
+	ctx := context.Background()
+	staged := true
+	w := &WorkspaceContext{
+		WorkspacePath: t.TempDir(),
+		Config: WorkspaceConfig{
+			Targets: map[string]*TargetConfiguration{
+				"lib": {Staged: &staged},
+				"app": {Depends: []string{"lib"}},
+			},
+		},
+	}
+
+	writeTestToolchain(t, w, "native", &Toolchain{TargetSystem: host.DetectHostPlatform(), TargetArch: host.DetectHostProcessor()})
+	writeTestToolchain(t, w, "cross", &Toolchain{TargetSystem: system.PlatformLinux, TargetArch: crossArch()})
+	writeTestToolchain(t, w, "sysroot", &Toolchain{
+		TargetSystem: system.PlatformLinux,
+		TargetArch:   crossArch(),
+		CMakeToolchain: map[string]CMakeToolchainOptions{
+			HostToolchainKey(): {Generate: &CMakeGenerateToolchainFileOptions{
+				CCompiler:    "clang",
+				Sysroot:      "sysroot",
+				TargetTriple: "aarch64-linux-gnu",
+			}},
+		},
+	})
+
+	sysroot := filepath.Join(w.WorkspacePath, "sysroot")
+	for _, dir := range SysrootPkgConfigDirs(sysroot, "aarch64-linux-gnu") {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app, err := w.GetTarget(ctx, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib, err := w.GetTarget(ctx, "lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		toolchain string
+		staged    bool
+		want      func(staged []string) RuntimeEnvironment
+	}{
+		{"native", false, func([]string) RuntimeEnvironment { return nil }},
+		{"native", true, func(staged []string) RuntimeEnvironment {
+			return RuntimeEnvironment{{Name: "PKG_CONFIG_PATH", Paths: staged}}
+		}},
+		{"cross", false, func([]string) RuntimeEnvironment {
+			return RuntimeEnvironment{{Name: "PKG_CONFIG_LIBDIR", Paths: []string{}, Replace: true}}
+		}},
+		{"cross", true, func(staged []string) RuntimeEnvironment {
+			return RuntimeEnvironment{
+				{Name: "PKG_CONFIG_PATH", Paths: staged},
+				{Name: "PKG_CONFIG_LIBDIR", Paths: staged, Replace: true},
+			}
+		}},
+		{"sysroot", true, func(staged []string) RuntimeEnvironment {
+			return RuntimeEnvironment{
+				{Name: "PKG_CONFIG_PATH", Paths: staged},
+				{Name: "PKG_CONFIG_LIBDIR", Paths: append(append([]string{}, staged...), SysrootPkgConfigDirs(sysroot, "aarch64-linux-gnu")...), Replace: true},
+				{Name: "PKG_CONFIG_SYSROOT_DIR", Paths: []string{sysroot}, Replace: true},
+			}
+		}},
+	} {
+		bp := TargetBuildParameters{Toolchain: tt.toolchain, BuildType: "Debug"}
+
+		var stagedDirs []string
+		if tt.staged {
+			stagingPath, err := lib.CMakeStagingPath(ctx, w, bp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stagedDirs = []string{filepath.Join(stagingPath, "lib", "pkgconfig")}
+			if err := os.MkdirAll(stagedDirs[0], 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got, err := w.PkgConfigEnvironment(ctx, app, bp)
+		if err != nil {
+			t.Fatalf("PkgConfigEnvironment(%s) error = %v", tt.toolchain, err)
+		}
+		if want := tt.want(stagedDirs); !reflect.DeepEqual(got, want) {
+			t.Errorf("PkgConfigEnvironment(%s, staged %v) = %+v, want %+v", tt.toolchain, tt.staged, got, want)
+		}
+	}
+}
+
+func TestEnvVarReplace(t *testing.T) {
+	v := EnvVar{Name: "PKG_CONFIG_LIBDIR", Paths: []string{"/staging"}, Replace: true}
+	got := RuntimeEnvironment{v}.Environ([]string{"PKG_CONFIG_LIBDIR=/usr/lib/pkgconfig"})
+	if want := []string{"PKG_CONFIG_LIBDIR=/staging"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Environ() = %v, want %v", got, want)
+	}
+}
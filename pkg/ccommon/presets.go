@@ -0,0 +1,236 @@
+package ccommon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cmakePresets is the subset of the CMakePresets.json schema (version 3) that
+// cbuild generates.
+type cmakePresets struct {
+	Version          int                    `json:"version"`
+	Vendor           map[string]any         `json:"vendor,omitempty"`
+	ConfigurePresets []cmakeConfigurePreset `json:"configurePresets"`
+	BuildPresets     []cmakeBuildPreset     `json:"buildPresets"`
+	TestPresets      []cmakeTestPreset      `json:"testPresets"`
+}
+
+type cmakeConfigurePreset struct {
+	Name           string         `json:"name"`
+	DisplayName    string         `json:"displayName,omitempty"`
+	Generator      string         `json:"generator,omitempty"`
+	BinaryDir      string         `json:"binaryDir"`
+	CacheVariables map[string]any `json:"cacheVariables,omitempty"`
+}
+
+type cmakeBuildPreset struct {
+	Name            string `json:"name"`
+	ConfigurePreset string `json:"configurePreset"`
+	Configuration   string `json:"configuration,omitempty"`
+}
+
+type cmakeTestPreset struct {
+	Name            string          `json:"name"`
+	ConfigurePreset string          `json:"configurePreset"`
+	Configuration   string          `json:"configuration,omitempty"`
+	Output          map[string]bool `json:"output,omitempty"`
+}
+
+type cmakeCacheVariable struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// presetsVendorKey marks CMakeUserPresets.json files written by cbuild, so they
+// can be regenerated without clobbering hand-written presets.
+const presetsVendorKey = "cbuild"
+
+// configureArgsToPreset converts the output of CMakeConfigureArgs into a
+// configure preset. Arguments with no preset equivalent are returned.
+func configureArgsToPreset(name string, args []string) (cmakeConfigurePreset, []string) {
+	preset := cmakeConfigurePreset{
+		Name:           name,
+		CacheVariables: make(map[string]any),
+	}
+	var ignored []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-S" && i+1 < len(args):
+			i++
+		case arg == "-B" && i+1 < len(args):
+			preset.BinaryDir = args[i+1]
+			i++
+		case arg == "-G" && i+1 < len(args):
+			preset.Generator = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "-D"):
+			def := strings.TrimPrefix(arg, "-D")
+			key, value, _ := strings.Cut(def, "=")
+			if varName, varType, ok := strings.Cut(key, ":"); ok {
+				preset.CacheVariables[varName] = cmakeCacheVariable{Type: varType, Value: value}
+			} else {
+				preset.CacheVariables[key] = value
+			}
+		default:
+			ignored = append(ignored, arg)
+		}
+	}
+
+	return preset, ignored
+}
+
+// GeneratePresets writes a CMakeUserPresets.json into the source directory of
+// each target, with a configure, build and test preset for every toolchain,
+// configuration and variant. The presets use the same build trees as cbuild.
+func (w *WorkspaceContext) GeneratePresets(ctx context.Context, targets []string, toolchains []string, configs []string, variants []string, force bool) error {
+	hostKey := HostToolchainKey()
+
+	var usableToolchains []string
+	unsupported := make(map[string]map[string]string)
+	for _, tcName := range toolchains {
+		tc, _, err := w.LoadToolchain(ctx, tcName)
+		if err != nil {
+			return fmt.Errorf("failed to load toolchain %s: %w", tcName, err)
+		}
+		if _, ok := tc.CMakeToolchain[hostKey]; !ok {
+			fmt.Printf("Toolchain %s has no %s entry, skipping\n", tcName, hostKey)
+			continue
+		}
+
+		bp := TargetBuildParameters{Toolchain: tcName}
+		_, err = w.Prebuild(ctx, bp)
+		if err != nil {
+			return err
+		}
+		unsupported[tcName], err = w.UnsupportedTargets(ctx, targets, bp)
+		if err != nil {
+			return err
+		}
+		for _, name := range targets {
+			if reason, ok := unsupported[tcName][name]; ok {
+				fmt.Printf("Skipping %s for toolchain %s: %s\n", name, tcName, reason)
+			}
+		}
+		usableToolchains = append(usableToolchains, tcName)
+	}
+
+	// Several targets can be built from the same source directory, their
+	// presets share one file and are prefixed with the target name.
+	bySource := make(map[string][]string)
+	for _, name := range targets {
+		t, err := w.GetTarget(ctx, name)
+		if err != nil {
+			return err
+		}
+		src, err := t.CMakeSourcePath(ctx, w)
+		if err != nil {
+			return err
+		}
+		bySource[src] = append(bySource[src], name)
+	}
+
+	sources := make([]string, 0, len(bySource))
+	for src := range bySource {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+
+	for _, src := range sources {
+		presets := cmakePresets{
+			Version: 3,
+			Vendor: map[string]any{
+				presetsVendorKey: map[string]any{"generated": true},
+			},
+			ConfigurePresets: []cmakeConfigurePreset{},
+			BuildPresets:     []cmakeBuildPreset{},
+			TestPresets:      []cmakeTestPreset{},
+		}
+
+		targetNames := bySource[src]
+		for _, name := range targetNames {
+			t, err := w.GetTarget(ctx, name)
+			if err != nil {
+				return err
+			}
+
+			for _, tcName := range usableToolchains {
+				if _, ok := unsupported[tcName][name]; ok {
+					continue
+				}
+				for _, cfg := range configs {
+					for _, variant := range variants {
+						bp := TargetBuildParameters{Toolchain: tcName, BuildType: cfg, Variant: variant}
+
+						presetName := fmt.Sprintf("%s-%s", tcName, bp.ConfigDirName())
+						if len(targetNames) > 1 {
+							presetName = fmt.Sprintf("%s-%s", name, presetName)
+						}
+
+						args, err := t.CMakeConfigureArgs(ctx, w, bp)
+						if err != nil {
+							return fmt.Errorf("failed to get cmake configure args for %s: %w", name, err)
+						}
+
+						configure, ignored := configureArgsToPreset(presetName, args)
+						configure.DisplayName = fmt.Sprintf("%s (%s, %s)", name, tcName, cfg)
+						if variant != "" {
+							configure.DisplayName = fmt.Sprintf("%s (%s, %s, %s)", name, tcName, cfg, variant)
+						}
+						for _, arg := range ignored {
+							fmt.Printf("Warning: %s: argument %q has no preset equivalent, skipping\n", presetName, arg)
+						}
+
+						presets.ConfigurePresets = append(presets.ConfigurePresets, configure)
+						presets.BuildPresets = append(presets.BuildPresets, cmakeBuildPreset{
+							Name:            presetName,
+							ConfigurePreset: presetName,
+							Configuration:   cfg,
+						})
+						presets.TestPresets = append(presets.TestPresets, cmakeTestPreset{
+							Name:            presetName,
+							ConfigurePreset: presetName,
+							Configuration:   cfg,
+							Output:          map[string]bool{"outputOnFailure": true},
+						})
+					}
+				}
+			}
+		}
+
+		err := writePresets(filepath.Join(src, "CMakeUserPresets.json"), presets, force)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writePresets(path string, presets cmakePresets, force bool) error {
+	if existing, err := os.ReadFile(path); err == nil && !force {
+		var old cmakePresets
+		if err := json.Unmarshal(existing, &old); err != nil || old.Vendor[presetsVendorKey] == nil {
+			return fmt.Errorf("%s was not generated by cbuild, use --force to overwrite it", path)
+		}
+	}
+
+	data, err := json.MarshalIndent(presets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal presets: %w", err)
+	}
+
+	err = os.WriteFile(path, append(data, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	fmt.Printf("Wrote %d presets to %s\n", len(presets.ConfigurePresets), path)
+	return nil
+}
@@ -0,0 +1,154 @@
+package ccommon
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/cmake"
+)
+
+// presetCacheArgs returns the -D arguments of a CMake command line as a map
+// from variable name to "TYPE=value" or "value". Later definitions win, as
+// they do for cmake.
+func presetCacheArgs(args []string) map[string]string {
+	vars := make(map[string]string)
+	for _, arg := range args {
+		def, ok := strings.CutPrefix(arg, "-D")
+		if !ok {
+			continue
+		}
+		key, value, _ := strings.Cut(def, "=")
+		name, varType, typed := strings.Cut(key, ":")
+		if typed {
+			value = varType + "=" + value
+		}
+		vars[name] = value
+	}
+	return vars
+}
+
+func TestGeneratePresetsMatchesConfigureArgs(t *testing.T) {
+	ctx := context.Background()
+	staged := true
+	std := "17"
+	w := &WorkspaceContext{
+		WorkspacePath: t.TempDir(),
+		Config: WorkspaceConfig{
+			CXXVersion:            "20",
+			ExportCompileCommands: true,
+			Variants: map[string]*Variant{
+				"asan": {CMakeOptions: map[string]cmake.Option{"ENABLE_ASAN": {Type: "BOOL", Value: "ON"}}},
+			},
+			Targets: map[string]*TargetConfiguration{
+				"lib":  {Staged: &staged},
+				"util": {},
+				"app": {
+					Depends:                 []string{"lib", "util"},
+					CxxStandard:             &std,
+					ExtraCMakeConfigureArgs: []string{"-DEXTRA=1"},
+					CMakeOptions:            map[string]cmake.Option{"APP_FEATURE": {Value: "yes"}},
+				},
+			},
+		},
+	}
+	writeTestToolchain(t, w, "tc", &Toolchain{
+		CompilerLauncher: "ccache",
+		CMakeToolchain:   map[string]CMakeToolchainOptions{HostToolchainKey(): {CMakeToolchainFile: "tc.cmake"}},
+	})
+	src := filepath.Join(w.WorkspacePath, "sources", "app")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	configs := []string{"Debug", "Release"}
+	variants := []string{"", "asan"}
+	err := w.GeneratePresets(ctx, []string{"app"}, []string{"tc"}, configs, variants, false)
+	if err != nil {
+		t.Fatalf("GeneratePresets() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(src, "CMakeUserPresets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var presets struct {
+		ConfigurePresets []struct {
+			Name           string         `json:"name"`
+			Generator      string         `json:"generator"`
+			BinaryDir      string         `json:"binaryDir"`
+			CacheVariables map[string]any `json:"cacheVariables"`
+		} `json:"configurePresets"`
+	}
+	if err := json.Unmarshal(data, &presets); err != nil {
+		t.Fatal(err)
+	}
+	if len(presets.ConfigurePresets) != len(configs)*len(variants) {
+		t.Fatalf("GeneratePresets() wrote %d configure presets, want %d", len(presets.ConfigurePresets), len(configs)*len(variants))
+	}
+
+	app, err := w.GetTarget(ctx, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := 0
+	for _, cfg := range configs {
+		for _, variant := range variants {
+			bp := TargetBuildParameters{Toolchain: "tc", BuildType: cfg, Variant: variant}
+			preset := presets.ConfigurePresets[i]
+			i++
+
+			if want := "tc-" + bp.ConfigDirName(); preset.Name != want {
+				t.Errorf("preset name = %q, want %q", preset.Name, want)
+			}
+
+			args, err := app.CMakeConfigureArgs(ctx, w, bp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := presetCacheArgs(args)
+
+			got := make(map[string]string)
+			for name, v := range preset.CacheVariables {
+				switch v := v.(type) {
+				case string:
+					got[name] = v
+				case map[string]any:
+					got[name] = v["type"].(string) + "=" + v["value"].(string)
+				default:
+					t.Errorf("%s: cacheVariables[%s] = %v, want a string or a typed value", preset.Name, name, v)
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: cacheVariables = %v, want %v", preset.Name, got, want)
+			}
+
+			binaryDir, err := app.CMakeBuildPath(ctx, w, bp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if preset.BinaryDir != binaryDir {
+				t.Errorf("%s: binaryDir = %q, want %q", preset.Name, preset.BinaryDir, binaryDir)
+			}
+			if preset.Generator != "Ninja" {
+				t.Errorf("%s: generator = %q, want Ninja", preset.Name, preset.Generator)
+			}
+			if tcFile := filepath.Join(w.WorkspacePath, "toolchains", "tc", "tc.cmake"); got["CMAKE_TOOLCHAIN_FILE"] != tcFile {
+				t.Errorf("%s: CMAKE_TOOLCHAIN_FILE = %q, want %q", preset.Name, got["CMAKE_TOOLCHAIN_FILE"], tcFile)
+			}
+			if got["CMAKE_CXX_STANDARD"] != "17" {
+				t.Errorf("%s: CMAKE_CXX_STANDARD = %q, want the target's 17", preset.Name, got["CMAKE_CXX_STANDARD"])
+			}
+			if utilDir := filepath.Join(w.WorkspacePath, "buildspaces", "tc", "util", bp.ConfigDirName()); got["util_DIR"] != utilDir {
+				t.Errorf("%s: util_DIR = %q, want %q", preset.Name, got["util_DIR"], utilDir)
+			}
+			if hasASan := got["ENABLE_ASAN"] != ""; hasASan != (variant == "asan") {
+				t.Errorf("%s: ENABLE_ASAN = %q", preset.Name, got["ENABLE_ASAN"])
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package ccommon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteWhy writes every dependency path from one target to another, one per
+// line.
+func (w *WorkspaceContext) WriteWhy(ctx context.Context, out io.Writer, from string, to string) error {
+	paths, err := w.DependencyPaths(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	if len(paths) == 0 {
+		fmt.Fprintf(&b, "%s does not depend on %s\n", from, to)
+	}
+	for _, path := range paths {
+		b.WriteString(strings.Join(path, " -> ") + "\n")
+	}
+
+	_, err = io.WriteString(out, b.String())
+	return err
+}
+
+// SplitDependents returns the targets that depend on a target, split into
+// those that depend on it directly and those that only depend on it through
+// other targets.
+func (w *WorkspaceContext) SplitDependents(ctx context.Context, targetName string) (direct []string, transitive []string, err error) {
+	dependents, err := w.TargetDependents(ctx, []string{targetName})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, name := range dependents {
+		deps, err := w.TargetDependencies(ctx, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		isDirect := false
+		for _, dep := range deps {
+			if dep == targetName {
+				isDirect = true
+				break
+			}
+		}
+		if isDirect {
+			direct = append(direct, name)
+		} else {
+			transitive = append(transitive, name)
+		}
+	}
+	return direct, transitive, nil
+}
+
+// WriteRdeps writes the targets that depend on a target, direct dependents
+// first.
+func (w *WorkspaceContext) WriteRdeps(ctx context.Context, out io.Writer, targetName string) error {
+	direct, transitive, err := w.SplitDependents(ctx, targetName)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	if len(direct) == 0 && len(transitive) == 0 {
+		fmt.Fprintf(&b, "No targets depend on %s\n", targetName)
+	} else {
+		b.WriteString("Direct:\n")
+		for _, name := range direct {
+			fmt.Fprintf(&b, "  %s\n", name)
+		}
+		if len(transitive) > 0 {
+			b.WriteString("Transitive:\n")
+			for _, name := range transitive {
+				fmt.Fprintf(&b, "  %s\n", name)
+			}
+		}
+	}
+
+	_, err = io.WriteString(out, b.String())
+	return err
+}
+
+// WriteTree writes a target and its dependencies as a tree. Targets whose
+// dependencies were already written are marked with (*) and not expanded
+// again, and dependencies that lead back to a target on the current path are
+// marked with (cycle).
+func (w *WorkspaceContext) WriteTree(ctx context.Context, out io.Writer, targetName string) error {
+	var b strings.Builder
+	b.WriteString(targetName + "\n")
+	err := w.writeTree(ctx, &b, targetName, "", map[string]bool{targetName: true}, map[string]bool{targetName: true})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(out, b.String())
+	return err
+}
+
+func (w *WorkspaceContext) writeTree(ctx context.Context, b *strings.Builder, name string, prefix string, expanded map[string]bool, onPath map[string]bool) error {
+	target, err := w.GetTarget(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	for i, dep := range target.Config.Depends {
+		branch, indent := "├── ", "│   "
+		if i == len(target.Config.Depends)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		depName := DependencyTargetName(dep)
+		depTarget, err := w.GetTarget(ctx, depName)
+		if err != nil {
+			fmt.Fprintf(b, "%s%s%s (missing)\n", prefix, branch, dep)
+			continue
+		}
+		depConfig := depTarget.Config
+
+		label := dep
+		if depConfig.Staged != nil && *depConfig.Staged {
+			label += " [staged]"
+		}
+
+		switch {
+		case onPath[depName]:
+			fmt.Fprintf(b, "%s%s%s (cycle)\n", prefix, branch, label)
+		case expanded[depName]:
+			if len(depConfig.Depends) > 0 {
+				label += " (*)"
+			}
+			fmt.Fprintf(b, "%s%s%s\n", prefix, branch, label)
+		default:
+			fmt.Fprintf(b, "%s%s%s\n", prefix, branch, label)
+			expanded[depName] = true
+			onPath[depName] = true
+			err := w.writeTree(ctx, b, depName, prefix+indent, expanded, onPath)
+			delete(onPath, depName)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
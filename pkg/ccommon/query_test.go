@@ -0,0 +1,117 @@
+package ccommon
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// queryWorkspace has the graph
+//
+//	app -> lib/core -> base
+//	app -> util -> lib, base
+//	broken -> missing
+//	x -> y -> x
+func queryWorkspace() *WorkspaceContext {
+	staged := true
+	return &WorkspaceContext{
+		Config: WorkspaceConfig{
+			Targets: map[string]*TargetConfiguration{
+				"base":   nil,
+				"lib":    {Depends: []string{"base"}},
+				"util":   {Depends: []string{"lib", "base"}, Staged: &staged},
+				"app":    {Depends: []string{"lib/core", "util"}},
+				"broken": {Depends: []string{"missing"}},
+				"x":      {Depends: []string{"y"}},
+				"y":      {Depends: []string{"x"}},
+			},
+		},
+	}
+}
+
+func TestWriteWhy(t *testing.T) {
+	w := queryWorkspace()
+
+	tests := []struct {
+		from, to string
+		want     string
+	}{
+		{"app", "base", "app -> lib -> base\napp -> util -> lib -> base\napp -> util -> base\n"},
+		{"app", "util", "app -> util\n"},
+		{"base", "app", "base does not depend on app\n"},
+	}
+
+	for _, tt := range tests {
+		var b strings.Builder
+		err := w.WriteWhy(context.Background(), &b, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("WriteWhy(%q, %q) error = %v", tt.from, tt.to, err)
+			continue
+		}
+		if b.String() != tt.want {
+			t.Errorf("WriteWhy(%q, %q) =\n%s\nwant\n%s", tt.from, tt.to, b.String(), tt.want)
+		}
+	}
+
+	if err := w.WriteWhy(context.Background(), &strings.Builder{}, "x", "base"); err == nil {
+		t.Errorf("WriteWhy through a cycle succeeded, want error")
+	}
+}
+
+func TestWriteRdeps(t *testing.T) {
+	w := queryWorkspace()
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"base", "Direct:\n  lib\n  util\nTransitive:\n  app\n"},
+		{"lib", "Direct:\n  app\n  util\n"},
+		{"app", "No targets depend on app\n"},
+		{"x", "Direct:\n  y\n"},
+	}
+
+	for _, tt := range tests {
+		var b strings.Builder
+		err := w.WriteRdeps(context.Background(), &b, tt.target)
+		if err != nil {
+			t.Errorf("WriteRdeps(%q) error = %v", tt.target, err)
+			continue
+		}
+		if b.String() != tt.want {
+			t.Errorf("WriteRdeps(%q) =\n%s\nwant\n%s", tt.target, b.String(), tt.want)
+		}
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	w := queryWorkspace()
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"app", `app
+├── lib/core
+│   └── base
+└── util [staged]
+    ├── lib (*)
+    └── base
+`},
+		{"base", "base\n"},
+		{"broken", "broken\n└── missing (missing)\n"},
+		{"x", "x\n└── y\n    └── x (cycle)\n"},
+	}
+
+	for _, tt := range tests {
+		var b strings.Builder
+		err := w.WriteTree(context.Background(), &b, tt.target)
+		if err != nil {
+			t.Errorf("WriteTree(%q) error = %v", tt.target, err)
+			continue
+		}
+		if b.String() != tt.want {
+			t.Errorf("WriteTree(%q) =\n%s\nwant\n%s", tt.target, b.String(), tt.want)
+		}
+	}
+}
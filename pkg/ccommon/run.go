@@ -0,0 +1,132 @@
+package ccommon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/cmake"
+)
+
+type RunConfiguration struct {
+	/// The executable to run by default. Either the name of a CMake executable
+	/// target, or a path relative to the target's build tree.
+	Executable string `yaml:"executable,omitempty"`
+
+	/// Arguments passed before any given on the command line.
+	Args []string `yaml:"args,omitempty"`
+
+	/// The working directory, relative to the workspace. Defaults to the
+	/// current directory.
+	WorkingDirectory string `yaml:"working_directory,omitempty"`
+}
+
+// RunSpec describes how to launch an executable built by a target.
+type RunSpec struct {
+	Path string
+	Args []string
+	Dir  string
+	Env  []string
+}
+
+// PrepareRun locates the executable exe of a built target and returns how to
+// run it with args and the runtime environment of the target. If exe is empty,
+// the target's run configuration is used, or its only executable.
+func (w *WorkspaceContext) PrepareRun(ctx context.Context, targetName string, exe string, args []string, bp TargetBuildParameters) (*RunSpec, error) {
+	t, err := w.GetTarget(ctx, targetName)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &RunSpec{}
+	runConfig := t.Config.Run
+	if runConfig != nil {
+		if exe == "" {
+			exe = runConfig.Executable
+		}
+		spec.Args = append(spec.Args, runConfig.Args...)
+		if runConfig.WorkingDirectory != "" {
+			spec.Dir = filepath.Join(w.WorkspacePath, runConfig.WorkingDirectory)
+		}
+	}
+	spec.Args = append(spec.Args, args...)
+
+	// The runtime environment checks the target's constraints, before
+	// findExecutable fails on a build tree that was never made.
+	env, err := w.RuntimeEnvironment(ctx, targetName, bp)
+	if err != nil {
+		return nil, err
+	}
+	spec.Env = env.Environ(os.Environ())
+
+	spec.Path, err = w.findExecutable(ctx, t, exe, bp)
+	if err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+func (w *WorkspaceContext) findExecutable(ctx context.Context, t *TargetContext, exe string, bp TargetBuildParameters) (string, error) {
+	buildPath, err := t.CMakeBuildPath(ctx, w, bp)
+	if err != nil {
+		return "", fmt.Errorf("failed to get build path: %w", err)
+	}
+	buildPath, err = filepath.Abs(buildPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute build path: %w", err)
+	}
+
+	if strings.ContainsAny(exe, `/\`) {
+		path := filepath.Join(buildPath, exe)
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("executable %s not found in the build tree of %s", exe, t.Name)
+		}
+		return path, nil
+	}
+
+	executables, apiErr := cmake.ReadExecutables(buildPath, bp.BuildType)
+	if apiErr == nil {
+		if exe == "" {
+			if len(executables) == 1 {
+				return executables[0].Path, nil
+			}
+			if len(executables) == 0 {
+				return "", fmt.Errorf("target %s has no executables", t.Name)
+			}
+			names := make([]string, len(executables))
+			for i, e := range executables {
+				names[i] = e.Name
+			}
+			return "", fmt.Errorf("target %s has several executables, select one of: %s", t.Name, strings.Join(names, ", "))
+		}
+
+		for _, e := range executables {
+			if e.Name == exe {
+				return e.Path, nil
+			}
+		}
+	}
+
+	// Staged targets restored from the cache have no build tree, but their
+	// installed executables can still be run.
+	if exe != "" && t.Config.Staged != nil && *t.Config.Staged {
+		stagingPath, err := t.CMakeStagingPath(ctx, w, bp)
+		if err != nil {
+			return "", fmt.Errorf("failed to get staging path: %w", err)
+		}
+		for _, name := range []string{exe, exe + ".exe"} {
+			path := filepath.Join(stagingPath, "bin", name)
+			if _, err := os.Stat(path); err == nil {
+				return filepath.Abs(path)
+			}
+		}
+	}
+
+	if apiErr != nil {
+		return "", fmt.Errorf("failed to find executables of %s: %w", t.Name, apiErr)
+	}
+	return "", fmt.Errorf("target %s has no executable named %s", t.Name, exe)
+}
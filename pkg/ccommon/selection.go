@@ -0,0 +1,115 @@
+package ccommon
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ResolveTargets resolves a target selection expression to target names, in
+// the order of ListTargets. The expression is a comma separated list of terms:
+// a target name, a glob such as "lib*", or "@tag" for every target with that
+// tag. Terms prefixed with "!", or "^" which needs no quoting in the shell,
+// exclude the targets they match. An expression with only exclusions starts
+// from all targets, and an empty expression selects all targets.
+func (w *WorkspaceContext) ResolveTargets(ctx context.Context, expr string) ([]string, error) {
+	all := w.ListTargets(ctx)
+
+	included := make(map[string]bool)
+	excluded := make(map[string]bool)
+	hasInclude := false
+
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		exclude := false
+		if strings.HasPrefix(term, "!") || strings.HasPrefix(term, "^") {
+			exclude = true
+			term = term[1:]
+		}
+
+		matches, err := w.matchTargets(ctx, all, term)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range matches {
+			if exclude {
+				excluded[name] = true
+			} else {
+				included[name] = true
+			}
+		}
+		if !exclude {
+			hasInclude = true
+		}
+	}
+
+	var targets []string
+	for _, name := range all {
+		if (!hasInclude || included[name]) && !excluded[name] {
+			targets = append(targets, name)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets match %q", expr)
+	}
+	return targets, nil
+}
+
+// ResolveTarget resolves a selection expression that must match exactly one target.
+func (w *WorkspaceContext) ResolveTarget(ctx context.Context, expr string) (string, error) {
+	targets, err := w.ResolveTargets(ctx, expr)
+	if err != nil {
+		return "", err
+	}
+	if len(targets) != 1 {
+		return "", fmt.Errorf("%q matches %d targets, expected one: %s", expr, len(targets), strings.Join(targets, ", "))
+	}
+	return targets[0], nil
+}
+
+func (w *WorkspaceContext) matchTargets(ctx context.Context, all []string, term string) ([]string, error) {
+	if tag, ok := strings.CutPrefix(term, "@"); ok {
+		var matches []string
+		for _, name := range all {
+			target := w.Config.Targets[name]
+			if target == nil {
+				continue
+			}
+			for _, t := range target.Tags {
+				if t == tag {
+					matches = append(matches, name)
+					break
+				}
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no targets are tagged %q", tag)
+		}
+		return matches, nil
+	}
+
+	if !strings.ContainsAny(term, "*?[") {
+		if _, ok := w.Config.Targets[term]; !ok {
+			return nil, fmt.Errorf("target %s not found in workspace", term)
+		}
+		return []string{term}, nil
+	}
+
+	var matches []string
+	for _, name := range all {
+		ok, err := path.Match(term, name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target pattern %q: %w", term, err)
+		}
+		if ok {
+			matches = append(matches, name)
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,84 @@
+package ccommon
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func selectionWorkspace() *WorkspaceContext {
+	return &WorkspaceContext{
+		Config: WorkspaceConfig{
+			Targets: map[string]*TargetConfiguration{
+				"libfoo":  {Tags: []string{"libs"}},
+				"libbar":  {Tags: []string{"libs", "slow"}},
+				"app":     {},
+				"tool":    {Tags: []string{"tools"}},
+				"empty":   nil,
+				"libtest": {Tags: []string{"tests"}},
+			},
+		},
+	}
+}
+
+func TestResolveTargets(t *testing.T) {
+	w := selectionWorkspace()
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"", []string{"app", "empty", "libbar", "libfoo", "libtest", "tool"}},
+		{"app", []string{"app"}},
+		{"tool,app", []string{"app", "tool"}},
+		{"lib*", []string{"libbar", "libfoo", "libtest"}},
+		{"lib?oo", []string{"libfoo"}},
+		{"@libs", []string{"libbar", "libfoo"}},
+		{"@libs,@tools", []string{"libbar", "libfoo", "tool"}},
+		{"lib*,!@slow", []string{"libfoo", "libtest"}},
+		{"lib*,^libtest", []string{"libbar", "libfoo"}},
+		{"!lib*", []string{"app", "empty", "tool"}},
+		{"^@libs,^empty", []string{"app", "libtest", "tool"}},
+		{" app , tool ", []string{"app", "tool"}},
+	}
+
+	for _, tt := range tests {
+		got, err := w.ResolveTargets(context.Background(), tt.expr)
+		if err != nil {
+			t.Errorf("ResolveTargets(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ResolveTargets(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestResolveTargetsErrors(t *testing.T) {
+	w := selectionWorkspace()
+
+	for _, expr := range []string{
+		"missing",
+		"@nope",
+		"lib[",
+		"app,!app",
+		"zzz*",
+	} {
+		if got, err := w.ResolveTargets(context.Background(), expr); err == nil {
+			t.Errorf("ResolveTargets(%q) = %v, want an error", expr, got)
+		}
+	}
+}
+
+func TestResolveTarget(t *testing.T) {
+	w := selectionWorkspace()
+
+	got, err := w.ResolveTarget(context.Background(), "@tools")
+	if err != nil || got != "tool" {
+		t.Errorf("ResolveTarget(@tools) = %q, %v, want tool", got, err)
+	}
+
+	if got, err := w.ResolveTarget(context.Background(), "lib*"); err == nil {
+		t.Errorf("ResolveTarget(lib*) = %q, want an error for several targets", got)
+	}
+}
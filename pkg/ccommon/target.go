@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"gitlab.com/rpnx/cbuild-go/pkg/cmake"
+	"gitlab.com/rpnx/cbuild-go/pkg/system"
 
 	"gopkg.in/yaml.v3"
 )
@@ -16,6 +17,29 @@ type TargetContext struct {
 	Config TargetConfiguration
 }
 
+// ArchConstraint lists the processors a target can be built for. Unlike a
+// toolchain's target_arch, an unrecognized processor is an error, as the
+// constraint could never match it.
+type ArchConstraint []system.Processor
+
+func (a *ArchConstraint) UnmarshalYAML(value *yaml.Node) error {
+	var names []string
+	if err := value.Decode(&names); err != nil {
+		return err
+	}
+
+	processors := make(ArchConstraint, 0, len(names))
+	for _, name := range names {
+		p, err := system.ParseProcessor(name)
+		if err != nil {
+			return fmt.Errorf("arch: %w", err)
+		}
+		processors = append(processors, p)
+	}
+	*a = processors
+	return nil
+}
+
 type TargetConfiguration struct {
 	/// The source to use from workspace sources, if empty, it's the source
 	/// of the same name as the target.
@@ -43,6 +67,21 @@ type TargetConfiguration struct {
 	ExtraCMakeConfigureArgs []string                `yaml:"extra_cmake_configure_args,omitempty"`
 	CMakeOptions            map[string]cmake.Option `yaml:"cmake_options,omitempty"`
 	CxxStandard             *string                 `yaml:"cxx_standard,omitempty"`
+
+	/// The target systems the target can be built for, any if empty.
+	Platforms []system.Platform `yaml:"platforms,omitempty"`
+
+	/// The target processors the target can be built for, any if empty.
+	Arch ArchConstraint `yaml:"arch,omitempty"`
+
+	/// Names or globs of the toolchains the target can be built with, any if empty.
+	Toolchains []string `yaml:"toolchains,omitempty"`
+
+	/// Tags for selecting groups of targets, e.g. `-t @tools`.
+	Tags []string `yaml:"tags,omitempty"`
+
+	/// How `cbuild run` launches the target's executables.
+	Run *RunConfiguration `yaml:"run,omitempty"`
 }
 
 func (m *TargetConfiguration) MarshalYAML() (interface{}, error) {
@@ -112,6 +151,10 @@ func (t *TargetContext) CMakeConfigureArgs(ctx context.Context, workspace *Works
 		args = append(args, fmt.Sprintf("-DCMAKE_CXX_STANDARD=%s", cxxStandard))
 	}
 
+	if workspace.Config.ExportCompileCommands {
+		args = append(args, "-DCMAKE_EXPORT_COMPILE_COMMANDS=ON")
+	}
+
 	toolchainFile, err := workspace.ToolchainFilePath(ctx, &t.Config, bp)
 	if err != nil {
 		return nil, err
@@ -120,6 +163,12 @@ func (t *TargetContext) CMakeConfigureArgs(ctx context.Context, workspace *Works
 		args = append(args, fmt.Sprintf("-DCMAKE_TOOLCHAIN_FILE=%s", toolchainFile))
 	}
 
+	launcherArgs, err := workspace.CompilerLauncherArgs(ctx, bp)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, launcherArgs...)
+
 	stagedPaths := []string{}
 	for _, dep := range t.Config.Depends {
 		parts := strings.SplitN(dep, "/", 2)
@@ -169,14 +218,18 @@ func (t *TargetContext) CMakeConfigureArgs(ctx context.Context, workspace *Works
 
 	args = append(args, t.Config.ExtraCMakeConfigureArgs...)
 
-	for optName, opt := range t.Config.CMakeOptions {
-		if opt.Type != "" {
-			args = append(args, fmt.Sprintf("-D%s:%s=%s", optName, opt.Type, opt.Value))
-		} else {
-			args = append(args, fmt.Sprintf("-D%s=%s", optName, opt.Value))
+	if bp.Variant != "" {
+		variant, ok := workspace.Config.Variants[bp.Variant]
+		if !ok {
+			return nil, fmt.Errorf("variant %s not found in workspace", bp.Variant)
+		}
+		if variant != nil {
+			args = append(args, cmakeOptionArgs(variant.CMakeOptions)...)
 		}
 	}
 
+	args = append(args, cmakeOptionArgs(t.Config.CMakeOptions)...)
+
 	return args, nil
 }
 
@@ -223,15 +276,15 @@ func (t *TargetContext) CMakeConfigPath(ctx context.Context, workspace *Workspac
 }
 
 func (t *TargetContext) CMakeBuildPath(ctx context.Context, workspace *WorkspaceContext, bp TargetBuildParameters) (string, error) {
-	return filepath.Join(workspace.WorkspacePath, "buildspaces", bp.Toolchain, t.Name, bp.BuildType), nil
+	return filepath.Join(workspace.WorkspacePath, "buildspaces", bp.Toolchain, t.Name, bp.ConfigDirName()), nil
 }
 
 func (t *TargetContext) CMakeStagingPath(ctx context.Context, workspace *WorkspaceContext, bp TargetBuildParameters) (string, error) {
-	return filepath.Join(workspace.WorkspacePath, "staging", bp.Toolchain, bp.BuildType, t.Name), nil
+	return filepath.Join(workspace.WorkspacePath, "staging", bp.Toolchain, bp.ConfigDirName(), t.Name), nil
 }
 
 func (t *TargetContext) CMakeExportPath(ctx context.Context, workspace *WorkspaceContext, bp TargetBuildParameters) (string, error) {
-	return filepath.Join(workspace.WorkspacePath, "exports", bp.Toolchain, t.Name, bp.BuildType), nil
+	return filepath.Join(workspace.WorkspacePath, "exports", bp.Toolchain, t.Name, bp.ConfigDirName()), nil
 }
 
 // CMakeDependencyArgs returns the arguments to pass to cmake when configuring another module that depends on this module
@@ -0,0 +1,340 @@
+package ccommon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ToolchainInfo summarizes a toolchain for `csetup toolchain list`.
+type ToolchainInfo struct {
+	Name      string
+	Toolchain *Toolchain
+
+	/// Whether the toolchain has a cmake_toolchain entry for this host.
+	AvailableOnHost bool
+
+	/// The cmake_toolchain keys of the toolchain, sorted.
+	Hosts []string
+}
+
+func (w *WorkspaceContext) toolchainDir(name string) string {
+	return filepath.Join(w.WorkspacePath, "toolchains", name)
+}
+
+// ResolveToolchainFile returns the path of the CMake toolchain file of a
+// cmake_toolchain entry, relative paths being relative to dir.
+func (o CMakeToolchainOptions) ResolveToolchainFile(dir string) string {
+	if o.CMakeToolchainFile == "" || filepath.IsAbs(o.CMakeToolchainFile) {
+		return o.CMakeToolchainFile
+	}
+	return filepath.Join(dir, o.CMakeToolchainFile)
+}
+
+func validToolchainName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid toolchain name %q", name)
+	}
+	return nil
+}
+
+// SaveToolchain writes the toolchain.yml of a toolchain.
+func (w *WorkspaceContext) SaveToolchain(ctx context.Context, name string, tc *Toolchain) error {
+	err := validToolchainName(name)
+	if err != nil {
+		return err
+	}
+
+	dir := w.toolchainDir(name)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create toolchain directory for %s: %w", name, err)
+	}
+
+	yamlFile, err := yaml.Marshal(tc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal toolchain %s: %w", name, err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "toolchain.yml"), yamlFile, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write toolchain file for %s: %w", name, err)
+	}
+	return nil
+}
+
+// ToolchainInfos returns a summary of every toolchain in the workspace.
+func (w *WorkspaceContext) ToolchainInfos(ctx context.Context) ([]ToolchainInfo, error) {
+	names, err := w.ListToolchains(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKey := HostToolchainKey()
+	var infos []ToolchainInfo
+	for _, name := range names {
+		tc, _, err := w.LoadToolchain(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("toolchain %s: %w", name, err)
+		}
+
+		info := ToolchainInfo{Name: name, Toolchain: tc}
+		for key := range tc.CMakeToolchain {
+			info.Hosts = append(info.Hosts, key)
+			if key == hostKey {
+				info.AvailableOnHost = true
+			}
+		}
+		sort.Strings(info.Hosts)
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// AddToolchainHost adds a cmake_toolchain entry for a host to a toolchain,
+// creating the toolchain if it does not exist. An existing entry for the host
+// is only replaced if force is set.
+func (w *WorkspaceContext) AddToolchainHost(ctx context.Context, name string, tc Toolchain, hostKey string, opts CMakeToolchainOptions, force bool) error {
+	err := validToolchainName(name)
+	if err != nil {
+		return err
+	}
+
+	existing := &tc
+	if _, err := os.Stat(filepath.Join(w.toolchainDir(name), "toolchain.yml")); err == nil {
+		// The toolchain is saved as written, so an extended toolchain is not
+		// merged into it; the target is checked against the merged one.
+		merged, _, err := w.LoadToolchain(ctx, name)
+		if err != nil {
+			return err
+		}
+		existing, _, err = w.readToolchain(name)
+		if err != nil {
+			return err
+		}
+		if merged.TargetSystem != tc.TargetSystem || merged.TargetArch != tc.TargetArch {
+			return fmt.Errorf("toolchain %s targets %s/%s, not %s/%s", name, merged.TargetSystem, merged.TargetArch, tc.TargetSystem, tc.TargetArch)
+		}
+		if _, ok := existing.CMakeToolchain[hostKey]; ok && !force {
+			return fmt.Errorf("toolchain %s already has an entry for %s, use --force to replace it", name, hostKey)
+		}
+	}
+
+	if existing.CMakeToolchain == nil {
+		existing.CMakeToolchain = make(map[string]CMakeToolchainOptions)
+	}
+	existing.CMakeToolchain[hostKey] = opts
+
+	err = w.SaveToolchain(ctx, name, existing)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added %s to toolchain %s\n", hostKey, name)
+	return nil
+}
+
+// toolchainChildren returns the toolchains that extend name directly, sorted.
+func (w *WorkspaceContext) toolchainChildren(ctx context.Context, name string) ([]string, error) {
+	names, err := w.ListToolchains(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var children []string
+	for _, child := range names {
+		tc, _, err := w.readToolchain(child)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("toolchain %s: %w", child, err)
+		}
+		if tc.Extends == name {
+			children = append(children, child)
+		}
+	}
+	sort.Strings(children)
+	return children, nil
+}
+
+// RemoveToolchain removes a toolchain definition. With deleteOutputs, its
+// build trees, staging and export directories are removed too. A toolchain
+// that other toolchains extend cannot be removed.
+func (w *WorkspaceContext) RemoveToolchain(ctx context.Context, name string, deleteOutputs bool) error {
+	err := validToolchainName(name)
+	if err != nil {
+		return err
+	}
+
+	dir := w.toolchainDir(name)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("toolchain %s not found", name)
+	}
+
+	children, err := w.toolchainChildren(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return fmt.Errorf("toolchain %s is extended by %s, remove them or change their extends first", name, strings.Join(children, ", "))
+	}
+
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return fmt.Errorf("failed to remove toolchain %s: %w", name, err)
+	}
+
+	if deleteOutputs {
+		for _, tree := range []string{"buildspaces", "staging", "exports"} {
+			err = w.removePath(filepath.Join(w.WorkspacePath, tree, name), false)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Printf("Removed toolchain %s\n", name)
+	return nil
+}
+
+// RenameToolchain renames a toolchain, and updates the extends of the
+// toolchains that extend it. Existing build trees are not moved, as CMake
+// build trees cannot be relocated; they are removed by `cbuild gc`.
+func (w *WorkspaceContext) RenameToolchain(ctx context.Context, from string, to string) error {
+	src, dst, err := w.toolchainPair(from, to)
+	if err != nil {
+		return err
+	}
+
+	children, err := w.toolchainChildren(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(src, dst)
+	if err != nil {
+		return fmt.Errorf("failed to rename toolchain %s: %w", from, err)
+	}
+
+	for _, child := range children {
+		if child == from {
+			child = to
+		}
+		tc, _, err := w.readToolchain(child)
+		if err != nil {
+			return fmt.Errorf("toolchain %s: %w", child, err)
+		}
+		tc.Extends = to
+		err = w.SaveToolchain(ctx, child, tc)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Updated toolchain %s to extend %s\n", child, to)
+	}
+
+	fmt.Printf("Renamed toolchain %s to %s\n", from, to)
+	return nil
+}
+
+// CopyToolchain copies a toolchain directory, including any toolchain files
+// it refers to, under a new name.
+func (w *WorkspaceContext) CopyToolchain(ctx context.Context, from string, to string) error {
+	src, dst, err := w.toolchainPair(from, to)
+	if err != nil {
+		return err
+	}
+
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy toolchain %s: %w", from, err)
+	}
+
+	fmt.Printf("Copied toolchain %s to %s\n", from, to)
+	return nil
+}
+
+func (w *WorkspaceContext) toolchainPair(from string, to string) (string, string, error) {
+	for _, name := range []string{from, to} {
+		err := validToolchainName(name)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	src := w.toolchainDir(from)
+	if _, err := os.Stat(src); err != nil {
+		return "", "", fmt.Errorf("toolchain %s not found", from)
+	}
+	dst := w.toolchainDir(to)
+	if _, err := os.Stat(dst); err == nil {
+		return "", "", fmt.Errorf("toolchain %s already exists", to)
+	}
+	return src, dst, nil
+}
+
+// HostToolchainFile returns the contents of the CMake toolchain file a
+// toolchain uses on this host, generating it if necessary.
+func (w *WorkspaceContext) HostToolchainFile(ctx context.Context, name string) (string, error) {
+	tc, tcPath, err := w.LoadToolchain(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	hostKey := HostToolchainKey()
+	tcf, ok := tc.CMakeToolchain[hostKey]
+	if !ok {
+		return "", fmt.Errorf("toolchain %s has no entry for %s", name, hostKey)
+	}
+
+	path := tcf.ResolveToolchainFile(tcPath)
+	if tcf.Generate != nil {
+		dir, err := os.MkdirTemp("", "cbuild_toolchain")
+		if err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(dir)
+
+		path = filepath.Join(dir, "generated_toolchain.cmake")
+		err = w.GenerateToolchainFile(ctx, tcf.Generate, w.ConfigurationDefinitions(tc), w.CompilerLauncher(tc), tc.TargetSystem, tc.TargetArch, path)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate toolchain file: %w", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read toolchain file: %w", err)
+	}
+	return string(data), nil
+}
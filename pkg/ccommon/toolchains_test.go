@@ -0,0 +1,184 @@
+package ccommon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/cmake"
+	"gitlab.com/rpnx/cbuild-go/pkg/host"
+	"gitlab.com/rpnx/cbuild-go/pkg/system"
+)
+
+// otherHostKey returns a cmake_toolchain key for a host other than this one.
+func otherHostKey() string {
+	return "host-" + host.DetectHostPlatform().StringLower() + "-" + crossArch().StringLower()
+}
+
+func TestAddToolchainHost(t *testing.T) {
+	ctx := context.Background()
+	w := &WorkspaceContext{WorkspacePath: t.TempDir()}
+	hostKey, otherKey := HostToolchainKey(), otherHostKey()
+	linux := Toolchain{TargetSystem: system.PlatformLinux, TargetArch: system.ProcessorX64}
+	native := CMakeToolchainOptions{CMakeToolchainFile: "native.cmake"}
+	generated := CMakeToolchainOptions{Generate: &CMakeGenerateToolchainFileOptions{CompilerType: cmake.CompilerTypeGCC, CCompiler: "gcc", CXXCompiler: "g++"}}
+
+	if err := w.AddToolchainHost(ctx, "gcc", linux, hostKey, native, false); err != nil {
+		t.Fatalf("AddToolchainHost(gcc, %s) error = %v", hostKey, err)
+	}
+	if err := w.AddToolchainHost(ctx, "gcc", linux, otherKey, generated, false); err != nil {
+		t.Fatalf("AddToolchainHost(gcc, %s) error = %v", otherKey, err)
+	}
+	tc, _, err := w.readToolchain("gcc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := linux
+	want.CMakeToolchain = map[string]CMakeToolchainOptions{hostKey: native, otherKey: generated}
+	if !reflect.DeepEqual(tc, &want) {
+		t.Errorf("after AddToolchainHost, gcc = %+v, want %+v", tc, &want)
+	}
+
+	replaced := CMakeToolchainOptions{CMakeToolchainFile: "replaced.cmake"}
+	err = w.AddToolchainHost(ctx, "gcc", linux, hostKey, replaced, false)
+	if err == nil || !strings.Contains(err.Error(), "already has an entry") {
+		t.Errorf("AddToolchainHost(gcc, %s) without force error = %v, want already has an entry", hostKey, err)
+	}
+	if err := w.AddToolchainHost(ctx, "gcc", linux, hostKey, replaced, true); err != nil {
+		t.Fatalf("AddToolchainHost(gcc, %s) with force error = %v", hostKey, err)
+	}
+	tc, _, err = w.readToolchain("gcc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.CMakeToolchain[hostKey] != replaced {
+		t.Errorf("after AddToolchainHost with force, %s = %+v, want %+v", hostKey, tc.CMakeToolchain[hostKey], replaced)
+	}
+
+	windows := Toolchain{TargetSystem: system.PlatformWindows, TargetArch: system.ProcessorX64}
+	err = w.AddToolchainHost(ctx, "gcc", windows, otherKey, native, true)
+	if err == nil || !strings.Contains(err.Error(), "targets") {
+		t.Errorf("AddToolchainHost(gcc) for another target error = %v, want a target mismatch", err)
+	}
+
+	// The target of a toolchain that extends another is checked against the
+	// merged toolchain, but only the new entry is written to it.
+	writeTestToolchain(t, w, "child", &Toolchain{Extends: "gcc"})
+	if err := w.AddToolchainHost(ctx, "child", linux, otherKey, native, false); err != nil {
+		t.Fatalf("AddToolchainHost(child) error = %v", err)
+	}
+	tc, _, err = w.readToolchain("child")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantChild := &Toolchain{Extends: "gcc", CMakeToolchain: map[string]CMakeToolchainOptions{otherKey: native}}
+	if !reflect.DeepEqual(tc, wantChild) {
+		t.Errorf("after AddToolchainHost, child = %+v, want %+v", tc, wantChild)
+	}
+
+	if err := w.AddToolchainHost(ctx, "../gcc", linux, hostKey, native, false); err == nil {
+		t.Errorf("AddToolchainHost(../gcc) succeeded, want an invalid name error")
+	}
+}
+
+func TestCopyToolchain(t *testing.T) {
+	ctx := context.Background()
+	w := &WorkspaceContext{WorkspacePath: t.TempDir()}
+	hostKey := HostToolchainKey()
+	orig := &Toolchain{
+		TargetSystem:     system.PlatformLinux,
+		TargetArch:       system.ProcessorArm64,
+		CompilerLauncher: "ccache",
+		CMakeToolchain:   map[string]CMakeToolchainOptions{hostKey: {CMakeToolchainFile: "cross.cmake"}},
+	}
+	writeTestToolchain(t, w, "cross", orig)
+	writeTestFile(t, filepath.Join(w.toolchainDir("cross"), "cross.cmake"), "set(CMAKE_SYSTEM_NAME Linux)\n")
+
+	if err := w.CopyToolchain(ctx, "cross", "cross2"); err != nil {
+		t.Fatalf("CopyToolchain(cross, cross2) error = %v", err)
+	}
+
+	copied, _, err := w.readToolchain("cross2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(copied, orig) {
+		t.Errorf("CopyToolchain(cross, cross2) toolchain.yml = %+v, want %+v", copied, orig)
+	}
+
+	// Relative toolchain files resolve to the copy.
+	file, err := w.HostToolchainFile(ctx, "cross2")
+	if err != nil {
+		t.Fatalf("HostToolchainFile(cross2) error = %v", err)
+	}
+	if file != "set(CMAKE_SYSTEM_NAME Linux)\n" {
+		t.Errorf("HostToolchainFile(cross2) = %q", file)
+	}
+	tc, dir, err := w.LoadToolchain(ctx, "cross2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tc.CMakeToolchain[hostKey].ResolveToolchainFile(dir), filepath.Join(w.toolchainDir("cross2"), "cross.cmake"); got != want {
+		t.Errorf("cross2 toolchain file = %s, want %s", got, want)
+	}
+	if _, err := os.Stat(w.toolchainDir("cross")); err != nil {
+		t.Errorf("CopyToolchain removed the original: %v", err)
+	}
+
+	for _, tt := range []struct{ from, to string }{
+		{"cross", "cross2"},
+		{"missing", "new"},
+		{"cross", "../escape"},
+	} {
+		if err := w.CopyToolchain(ctx, tt.from, tt.to); err == nil {
+			t.Errorf("CopyToolchain(%s, %s) succeeded, want error", tt.from, tt.to)
+		}
+	}
+}
+
+func TestToolchainInfos(t *testing.T) {
+	ctx := context.Background()
+	w := &WorkspaceContext{WorkspacePath: t.TempDir()}
+	hostKey, otherKey := HostToolchainKey(), otherHostKey()
+
+	infos, err := w.ToolchainInfos(ctx)
+	if err != nil || len(infos) != 0 {
+		t.Errorf("ToolchainInfos() without toolchains = %v, %v, want none", infos, err)
+	}
+
+	writeTestToolchain(t, w, "both", &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{otherKey: {}, hostKey: {}}})
+	writeTestToolchain(t, w, "elsewhere", &Toolchain{CMakeToolchain: map[string]CMakeToolchainOptions{otherKey: {}}})
+	writeTestToolchain(t, w, "inherits", &Toolchain{Extends: "both"})
+	writeTestToolchain(t, w, "none", &Toolchain{})
+
+	infos, err = w.ToolchainInfos(ctx)
+	if err != nil {
+		t.Fatalf("ToolchainInfos() error = %v", err)
+	}
+
+	type summary struct {
+		Name      string
+		Available bool
+		Hosts     []string
+	}
+	var got []summary
+	for _, info := range infos {
+		got = append(got, summary{info.Name, info.AvailableOnHost, info.Hosts})
+	}
+	hosts := []string{hostKey, otherKey}
+	if otherKey < hostKey {
+		hosts = []string{otherKey, hostKey}
+	}
+	want := []summary{
+		{"both", true, hosts},
+		{"elsewhere", false, []string{otherKey}},
+		{"inherits", true, hosts},
+		{"none", false, nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToolchainInfos() = %+v, want %+v", got, want)
+	}
+}
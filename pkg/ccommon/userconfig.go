@@ -0,0 +1,50 @@
+package ccommon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UserConfig holds per-user settings from ~/.config/cbuild/config.yml. Settings
+// in the workspace take precedence over the user config.
+type UserConfig struct {
+	Cache *CacheConfig `yaml:"cache,omitempty"`
+
+	/// Directories `csetup detect-toolchains` searches for compilers before PATH.
+	CompilerSearchDirs []string `yaml:"compiler_search_dirs,omitempty"`
+}
+
+func UserConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine user config directory: %w", err)
+	}
+	return filepath.Join(dir, "cbuild", "config.yml"), nil
+}
+
+// LoadUserConfig reads the user config. A missing file yields an empty config.
+func LoadUserConfig() (*UserConfig, error) {
+	cfg := &UserConfig{}
+
+	path, err := UserConfigPath()
+	if err != nil {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("failed to read user config: %w", err)
+	}
+
+	err = yaml.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse user config %s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,125 @@
+package ccommon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/cmake"
+)
+
+type Variant struct {
+	/// CMake options set for every target built in this variant. Options set
+	/// on a target take precedence.
+	CMakeOptions map[string]cmake.Option `yaml:"cmake_options"`
+}
+
+// variantSeparator separates the configuration from the variant in
+// ConfigDirName. Configuration names cannot contain it, so "Debug@shared"
+// cannot be mistaken for a configuration.
+const variantSeparator = "@"
+
+// ConfigDirName returns the name of the directory for the configuration and
+// variant in build, staging and export paths, e.g. "Debug" or "Debug@shared".
+func (bp TargetBuildParameters) ConfigDirName() string {
+	if bp.Variant == "" {
+		return bp.BuildType
+	}
+	return bp.BuildType + variantSeparator + bp.Variant
+}
+
+// ValidateConfigurationName returns an error if name cannot be used as a
+// configuration name.
+func ValidateConfigurationName(name string) error {
+	return validateDirName("configuration", name)
+}
+
+// ValidateVariantName returns an error if name cannot be used as a variant
+// name.
+func ValidateVariantName(name string) error {
+	return validateDirName("variant", name)
+}
+
+// validateDirName checks a configuration or variant name, which are part of
+// build, staging and export paths.
+func validateDirName(kind string, name string) error {
+	if name == "" {
+		return fmt.Errorf("%s name cannot be empty", kind)
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, variantSeparator+`/\`) {
+		return fmt.Errorf("%s name %q cannot be . or .., or contain %q, / or \\", kind, name, variantSeparator)
+	}
+	return nil
+}
+
+// ResolveConfigurations returns the configurations named by a comma separated
+// list, or the workspace configurations if it is empty.
+func (w *WorkspaceContext) ResolveConfigurations(list string) ([]string, error) {
+	if list == "" {
+		return w.Config.Configurations, nil
+	}
+
+	var configs []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		err := ValidateConfigurationName(name)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, name)
+	}
+	return configs, nil
+}
+
+// ListVariants returns the names of the workspace variants, sorted.
+func (w *WorkspaceContext) ListVariants() []string {
+	variants := make([]string, 0, len(w.Config.Variants))
+	for name := range w.Config.Variants {
+		variants = append(variants, name)
+	}
+	sort.Strings(variants)
+	return variants
+}
+
+// ResolveVariants returns the variants named by a comma separated list, or all
+// variants if it is empty. A workspace without variants has the single
+// unnamed variant "".
+func (w *WorkspaceContext) ResolveVariants(list string) ([]string, error) {
+	if list == "" {
+		if len(w.Config.Variants) == 0 {
+			return []string{""}, nil
+		}
+		return w.ListVariants(), nil
+	}
+
+	var variants []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := w.Config.Variants[name]; !ok {
+			return nil, fmt.Errorf("variant %s not found in workspace", name)
+		}
+		variants = append(variants, name)
+	}
+	return variants, nil
+}
+
+// cmakeOptionArgs returns -D arguments for options, sorted by name so the
+// arguments are stable between runs.
+func cmakeOptionArgs(options map[string]cmake.Option) []string {
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, 0, len(names))
+	for _, name := range names {
+		opt := options[name]
+		if opt.Type != "" {
+			args = append(args, fmt.Sprintf("-D%s:%s=%s", name, opt.Type, opt.Value))
+		} else {
+			args = append(args, fmt.Sprintf("-D%s=%s", name, opt.Value))
+		}
+	}
+	return args
+}
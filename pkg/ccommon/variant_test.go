@@ -0,0 +1,152 @@
+package ccommon
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfigDirName(t *testing.T) {
+	tests := []struct {
+		bp   TargetBuildParameters
+		want string
+	}{
+		{TargetBuildParameters{BuildType: "Debug"}, "Debug"},
+		{TargetBuildParameters{BuildType: "Debug", Variant: "shared"}, "Debug@shared"},
+		{TargetBuildParameters{BuildType: "Debug-shared"}, "Debug-shared"},
+		{TargetBuildParameters{BuildType: "RelWithDebInfo", Variant: "no-exceptions"}, "RelWithDebInfo@no-exceptions"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.bp.ConfigDirName(); got != tt.want {
+			t.Errorf("ConfigDirName(%q, %q) = %q, want %q", tt.bp.BuildType, tt.bp.Variant, got, tt.want)
+		}
+	}
+
+	// A configuration named like a configuration and variant pair must not
+	// share its directory.
+	plain := TargetBuildParameters{BuildType: "Debug-shared"}.ConfigDirName()
+	variant := TargetBuildParameters{BuildType: "Debug", Variant: "shared"}.ConfigDirName()
+	if plain == variant {
+		t.Errorf("ConfigDirName() = %q for both Debug-shared and Debug with variant shared", plain)
+	}
+}
+
+func TestValidateConfigurationName(t *testing.T) {
+	for _, name := range []string{"Debug", "Debug-shared", "my_config"} {
+		if err := ValidateConfigurationName(name); err != nil {
+			t.Errorf("ValidateConfigurationName(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "Debug@shared", "..", ".", "../x", "a/b", `a\b`} {
+		if err := ValidateConfigurationName(name); err == nil {
+			t.Errorf("ValidateConfigurationName(%q) = nil, want an error", name)
+		}
+	}
+
+	var config WorkspaceConfig
+	if err := yaml.Unmarshal([]byte("configurations: [Debug, Debug@shared]"), &config); err == nil {
+		t.Errorf("yaml.Unmarshal() of a configuration containing @ = nil, want an error")
+	}
+	if err := yaml.Unmarshal([]byte("configurations:\n  Debug@shared: {}\n"), &config); err == nil {
+		t.Errorf("yaml.Unmarshal() of a configuration definition containing @ = nil, want an error")
+	}
+}
+
+func TestValidateVariantName(t *testing.T) {
+	for _, name := range []string{"shared", "no-exceptions", "asan_ubsan"} {
+		if err := ValidateVariantName(name); err != nil {
+			t.Errorf("ValidateVariantName(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "a@b", ".", "..", "a/b", "../../etc", `a\b`} {
+		if err := ValidateVariantName(name); err == nil {
+			t.Errorf("ValidateVariantName(%q) = nil, want an error", name)
+		}
+	}
+
+	for _, doc := range []string{
+		"variants:\n  shared: {}\n  a@b: {}\n",
+		"variants:\n  ../escape: {}\n",
+		"variants:\n  \"\": {}\n",
+	} {
+		var config WorkspaceConfig
+		if err := yaml.Unmarshal([]byte(doc), &config); err == nil {
+			t.Errorf("yaml.Unmarshal(%q) = nil, want an error", doc)
+		}
+	}
+}
+
+func TestResolveConfigurations(t *testing.T) {
+	w := &WorkspaceContext{Config: WorkspaceConfig{Configurations: []string{"Debug", "Release"}}}
+
+	tests := []struct {
+		list string
+		want []string
+	}{
+		{"", []string{"Debug", "Release"}},
+		{"Release", []string{"Release"}},
+		{"Debug, Quick", []string{"Debug", "Quick"}},
+	}
+	for _, tt := range tests {
+		got, err := w.ResolveConfigurations(tt.list)
+		if err != nil {
+			t.Errorf("ResolveConfigurations(%q) error = %v", tt.list, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ResolveConfigurations(%q) = %q, want %q", tt.list, got, tt.want)
+		}
+	}
+
+	for _, list := range []string{"Debug@shared", "Debug,", "../Debug"} {
+		if got, err := w.ResolveConfigurations(list); err == nil {
+			t.Errorf("ResolveConfigurations(%q) = %q, want an error", list, got)
+		}
+	}
+}
+
+func TestResolveVariants(t *testing.T) {
+	none := &WorkspaceContext{}
+	w := &WorkspaceContext{
+		Config: WorkspaceConfig{
+			Variants: map[string]*Variant{"static": {}, "shared": {}, "asan": {}},
+		},
+	}
+
+	tests := []struct {
+		w    *WorkspaceContext
+		list string
+		want []string
+	}{
+		{none, "", []string{""}},
+		{w, "", []string{"asan", "shared", "static"}},
+		{w, "shared", []string{"shared"}},
+		{w, "static, shared", []string{"static", "shared"}},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.w.ResolveVariants(tt.list)
+		if err != nil {
+			t.Errorf("ResolveVariants(%q) error = %v", tt.list, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ResolveVariants(%q) = %q, want %q", tt.list, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		w    *WorkspaceContext
+		list string
+	}{
+		{w, "missing"},
+		{w, "shared,missing"},
+		{none, "shared"},
+	} {
+		if got, err := tt.w.ResolveVariants(tt.list); err == nil {
+			t.Errorf("ResolveVariants(%q) = %q, want an error", tt.list, got)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package ccommon
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type WatchOptions struct {
+	// Interval is how often the source trees are scanned for changes.
+	Interval time.Duration
+	// Debounce is how long the sources must stay unchanged before rebuilding.
+	Debounce time.Duration
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+type sourceSnapshot map[string]fileStamp
+
+// watchedSource is the source tree of a target.
+type watchedSource struct {
+	target string
+	path   string
+}
+
+// Watch builds the given targets, then polls the source trees of the targets
+// and their dependencies, rebuilding changed targets and the watched targets
+// that depend on them until ctx is cancelled.
+func (w *WorkspaceContext) Watch(ctx context.Context, roots []string, bp TargetBuildParameters, opts WatchOptions) error {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Debounce <= 0 {
+		opts.Debounce = 500 * time.Millisecond
+	}
+
+	watched, err := w.TargetBuildOrder(ctx, roots)
+	if err != nil {
+		return err
+	}
+	isWatched := make(map[string]bool)
+	var sources []watchedSource
+	for _, name := range watched {
+		isWatched[name] = true
+		t, err := w.GetTarget(ctx, name)
+		if err != nil {
+			return err
+		}
+		src, err := t.CMakeSourcePath(ctx, w)
+		if err != nil {
+			return err
+		}
+		src, err = filepath.Abs(src)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, watchedSource{target: name, path: src})
+	}
+
+	snapshot := snapshotSources(sources)
+	w.watchBuild(ctx, watched, bp)
+
+	for {
+		changed, next, err := w.waitForChanges(ctx, sources, snapshot, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		snapshot = next
+
+		owners := ownersOf(sources, changed)
+		if len(owners) == 0 {
+			continue
+		}
+
+		order, err := w.SelectTargets(ctx, owners, BuildSelection{Dependents: true, NoDeps: true})
+		if err != nil {
+			return err
+		}
+		var rebuild []string
+		for _, name := range order {
+			if isWatched[name] {
+				rebuild = append(rebuild, name)
+			}
+		}
+
+		fmt.Printf("[%s] %d files changed in %s\n", time.Now().Format("15:04:05"), len(changed), strings.Join(owners, ", "))
+		w.watchBuild(ctx, rebuild, bp)
+	}
+}
+
+// watchBuild builds targets in order and prints a one line summary. Build
+// failures are reported but do not stop watching.
+func (w *WorkspaceContext) watchBuild(ctx context.Context, order []string, bp TargetBuildParameters) {
+	start := time.Now()
+	err := w.buildOrdered(ctx, order, bp)
+	elapsed := time.Since(start).Round(100 * time.Millisecond)
+	stamp := time.Now().Format("15:04:05")
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Printf("[%s] FAILED after %s: %v -- waiting for changes\n", stamp, elapsed, err)
+		return
+	}
+	fmt.Printf("[%s] OK %s (%d targets, %s) -- waiting for changes\n", stamp, strings.Join(order, ", "), len(order), elapsed)
+}
+
+// waitForChanges polls the sources until they change and then stay unchanged
+// for the debounce period. It returns the changed files and the new snapshot.
+func (w *WorkspaceContext) waitForChanges(ctx context.Context, sources []watchedSource, snapshot sourceSnapshot, opts WatchOptions) ([]string, sourceSnapshot, error) {
+	changed := make(map[string]bool)
+	var lastChange time.Time
+
+	for {
+		wait := opts.Interval
+		if len(changed) > 0 {
+			wait = opts.Debounce
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		next := snapshotSources(sources)
+		diff := diffSnapshots(snapshot, next)
+		snapshot = next
+		if len(diff) > 0 {
+			for _, path := range diff {
+				changed[path] = true
+			}
+			lastChange = time.Now()
+			continue
+		}
+
+		if len(changed) > 0 && time.Since(lastChange) >= opts.Debounce {
+			paths := make([]string, 0, len(changed))
+			for path := range changed {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			return paths, snapshot, nil
+		}
+	}
+}
+
+// snapshotSources records the modification time and size of every file in the
+// source trees. Hidden directories such as .git are skipped.
+func snapshotSources(sources []watchedSource) sourceSnapshot {
+	snapshot := make(sourceSnapshot)
+	for _, src := range sources {
+		filepath.WalkDir(src.path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path != src.path && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if _, ok := snapshot[path]; ok {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			snapshot[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+	}
+	return snapshot
+}
+
+func diffSnapshots(old sourceSnapshot, next sourceSnapshot) []string {
+	var changed []string
+	for path, stamp := range next {
+		prev, ok := old[path]
+		if !ok || !prev.modTime.Equal(stamp.modTime) || prev.size != stamp.size {
+			changed = append(changed, path)
+		}
+	}
+	for path := range old {
+		if _, ok := next[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// ownersOf returns the targets whose source trees contain any of the files.
+// A file inside several source trees, e.g. with nested root paths, belongs to
+// all of them.
+func ownersOf(sources []watchedSource, files []string) []string {
+	owned := make(map[string]bool)
+	for _, file := range files {
+		for _, src := range sources {
+			if file == src.path || strings.HasPrefix(file, src.path+string(os.PathSeparator)) {
+				owned[src.target] = true
+			}
+		}
+	}
+
+	owners := make([]string, 0, len(owned))
+	for name := range owned {
+		owners = append(owners, name)
+	}
+	sort.Strings(owners)
+	return owners
+}
@@ -0,0 +1,98 @@
+package ccommon
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	t1 := time.Unix(2000, 0)
+	old := sourceSnapshot{
+		"/src/same.c":    {modTime: t0, size: 10},
+		"/src/touched.c": {modTime: t0, size: 10},
+		"/src/grown.c":   {modTime: t0, size: 10},
+		"/src/removed.c": {modTime: t0, size: 10},
+	}
+	next := sourceSnapshot{
+		"/src/same.c":    {modTime: t0, size: 10},
+		"/src/touched.c": {modTime: t1, size: 10},
+		"/src/grown.c":   {modTime: t0, size: 20},
+		"/src/added.c":   {modTime: t0, size: 10},
+	}
+
+	got := diffSnapshots(old, next)
+	sort.Strings(got)
+	want := []string{"/src/added.c", "/src/grown.c", "/src/removed.c", "/src/touched.c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffSnapshots() = %v, want %v", got, want)
+	}
+
+	if got := diffSnapshots(next, next); len(got) != 0 {
+		t.Errorf("diffSnapshots() of equal snapshots = %v, want none", got)
+	}
+}
+
+func TestOwnersOf(t *testing.T) {
+	sep := string(os.PathSeparator)
+	repo := filepath.Join(sep, "ws", "sources", "repo")
+	sources := []watchedSource{
+		{target: "all", path: repo},
+		{target: "core", path: filepath.Join(repo, "core")},
+		{target: "core-tests", path: filepath.Join(repo, "core", "tests")},
+		{target: "other", path: filepath.Join(sep, "ws", "sources", "other")},
+	}
+
+	tests := []struct {
+		files []string
+		want  []string
+	}{
+		{[]string{filepath.Join(repo, "CMakeLists.txt")}, []string{"all"}},
+		{[]string{filepath.Join(repo, "core", "core.c")}, []string{"all", "core"}},
+		{[]string{filepath.Join(repo, "core", "tests", "test.c")}, []string{"all", "core", "core-tests"}},
+		{[]string{filepath.Join(repo, "core", "tests")}, []string{"all", "core", "core-tests"}},
+		// A sibling sharing a prefix is not inside the tree.
+		{[]string{filepath.Join(repo, "core2", "x.c")}, []string{"all"}},
+		{[]string{filepath.Join(sep, "ws", "sources", "other2", "x.c")}, []string{}},
+		{[]string{filepath.Join(sep, "ws", "sources", "other", "x.c"), filepath.Join(repo, "core", "y.c")}, []string{"all", "core", "other"}},
+		{nil, []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := ownersOf(sources, tt.files); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ownersOf(%v) = %v, want %v", tt.files, got, tt.want)
+		}
+	}
+}
+
+func TestSnapshotSources(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "CMakeLists.txt"), "project(x)")
+	writeTestFile(t, filepath.Join(root, "sub", "a.c"), "int a;")
+	writeTestFile(t, filepath.Join(root, ".git", "HEAD"), "ref")
+	writeTestFile(t, filepath.Join(root, "sub", ".cache", "x"), "x")
+
+	// Nested roots share their files instead of listing them twice.
+	snapshot := snapshotSources([]watchedSource{
+		{target: "outer", path: root},
+		{target: "inner", path: filepath.Join(root, "sub")},
+	})
+
+	var got []string
+	for path := range snapshot {
+		got = append(got, path)
+	}
+	sort.Strings(got)
+	want := []string{filepath.Join(root, "CMakeLists.txt"), filepath.Join(root, "sub", "a.c")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshotSources() = %v, want %v", got, want)
+	}
+
+	if stamp := snapshot[filepath.Join(root, "sub", "a.c")]; stamp.size != int64(len("int a;")) {
+		t.Errorf("snapshotSources() size of a.c = %d, want %d", stamp.size, len("int a;"))
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"gitlab.com/rpnx/cbuild-go/pkg/cli"
@@ -22,6 +23,10 @@ type WorkspaceContext struct {
 	Config        WorkspaceConfig
 	WorkspacePath string
 	DownloadDeps  bool
+	State         *BuildState
+	UserConfig    *UserConfig
+
+	compilerIdentities map[string]*CompilerIdentity
 }
 
 type WorkspaceConfig struct {
@@ -31,6 +36,26 @@ type WorkspaceConfig struct {
 	CMakeBinary    *string  `yaml:"cmake_binary"`
 	CXXVersion     string   `yaml:"cxx_version"`
 	Configurations []string `yaml:"configurations"`
+
+	/// Flags for the configurations, from the mapping form of `configurations:`.
+	ConfigurationDefinitions map[string]*cmake.ConfigurationDefinition `yaml:"-"`
+
+	/// Configure every target with CMAKE_EXPORT_COMPILE_COMMANDS=ON.
+	ExportCompileCommands bool `yaml:"export_compile_commands,omitempty"`
+
+	Cache *CacheConfig `yaml:"cache,omitempty"`
+
+	/// Named sets of cmake options, built as a third axis alongside
+	/// toolchains and configurations.
+	Variants map[string]*Variant `yaml:"variants,omitempty"`
+
+	/// What to do when a toolchain's compiler no longer matches the one
+	/// recorded in toolchain.yml: warn (default), error or off.
+	CompilerCheck string `yaml:"compiler_check,omitempty"`
+
+	/// A command the compilers of every toolchain are run through, such as
+	/// ccache or sccache.
+	CompilerLauncher string `yaml:"compiler_launcher,omitempty"`
 }
 
 func (w *WorkspaceContext) Load(ctx context.Context, path string) error {
@@ -53,6 +78,11 @@ func (w *WorkspaceContext) Load(ctx context.Context, path string) error {
 		w.Config.Configurations = []string{"Debug", "Release"}
 	}
 
+	w.UserConfig, err = LoadUserConfig()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -62,10 +92,12 @@ func (w *WorkspaceContext) GetTarget(ctx context.Context, name string) (*TargetC
 		return nil, fmt.Errorf("target %s not found in workspace", name)
 	}
 
-	return &TargetContext{
-		Name:   name,
-		Config: *targetConfig,
-	}, nil
+	// An entry without settings, e.g. `foo:`, is a target with defaults.
+	mod := &TargetContext{Name: name}
+	if targetConfig != nil {
+		mod.Config = *targetConfig
+	}
+	return mod, nil
 }
 
 func (w *WorkspaceContext) Save(ctx context.Context) error {
@@ -82,20 +114,35 @@ func (w *WorkspaceContext) Save(ctx context.Context) error {
 	return nil
 }
 
-func (w *WorkspaceContext) GenerateToolchainFile(ctx context.Context, opts *CMakeGenerateToolchainFileOptions, systemName system.Platform, systemProcessor system.Processor, targetPath string) error {
+func (w *WorkspaceContext) GenerateToolchainFile(ctx context.Context, opts *CMakeGenerateToolchainFileOptions, configurations map[string]*cmake.ConfigurationDefinition, launcher string, systemName system.Platform, systemProcessor system.Processor, targetPath string) error {
 	return cmake.GenerateToolchainFile(ctx, cmake.GenerateToolchainFileOptions{
-		CCompiler:       opts.CCompiler,
-		CXXCompiler:     opts.CXXCompiler,
-		Linker:          opts.Linker,
-		ExtraCXXFlags:   opts.ExtraCXXFlags,
-		SystemPlatform:  systemName,
-		SystemProcessor: systemProcessor,
-		WorkspaceDir:    w.WorkspacePath,
-		OutputFile:      targetPath,
+		CompilerType:           opts.CompilerType,
+		CCompiler:              opts.CCompiler,
+		CXXCompiler:            opts.CXXCompiler,
+		Linker:                 opts.Linker,
+		LinkerType:             opts.LinkerType,
+		ExtraCompilerFlags:     opts.ExtraCompilerFlags,
+		ExtraCFlags:            opts.ExtraCFlags,
+		ExtraCXXFlags:          opts.ExtraCXXFlags,
+		ExtraLinkerFlags:       opts.ExtraLinkerFlags,
+		ExtraSharedLinkerFlags: opts.ExtraSharedLinkerFlags,
+		ExtraExeLinkerFlags:    opts.ExtraExeLinkerFlags,
+		Sysroot:                opts.Sysroot,
+		TargetTriple:           opts.TargetTriple,
+		FindRootPath:           opts.FindRootPath,
+		FindRootPathModes:      opts.FindRootPathModes,
+		CompilerLauncher:       launcher,
+		SystemPlatform:         systemName,
+		SystemProcessor:        systemProcessor,
+		WorkspaceDir:           w.WorkspacePath,
+		OutputFile:             targetPath,
+		Configurations:         configurations,
 	})
 }
 
-func (w *WorkspaceContext) LoadToolchain(ctx context.Context, toolchainName string) (*Toolchain, string, error) {
+// readToolchain reads the toolchain.yml of a toolchain as written, without
+// merging the toolchain it extends.
+func (w *WorkspaceContext) readToolchain(toolchainName string) (*Toolchain, string, error) {
 	toolchainDir := filepath.Join(w.WorkspacePath, "toolchains", toolchainName)
 	toolchainFile := filepath.Join(toolchainDir, "toolchain.yml")
 
@@ -113,19 +160,52 @@ func (w *WorkspaceContext) LoadToolchain(ctx context.Context, toolchainName stri
 	return tc, toolchainDir, nil
 }
 
+// LoadToolchain loads a toolchain, merged with the toolchains it extends.
+func (w *WorkspaceContext) LoadToolchain(ctx context.Context, toolchainName string) (*Toolchain, string, error) {
+	return w.loadToolchain(ctx, toolchainName, nil)
+}
+
+func (w *WorkspaceContext) loadToolchain(ctx context.Context, toolchainName string, path []string) (*Toolchain, string, error) {
+	for _, name := range path {
+		if name == toolchainName {
+			return nil, "", fmt.Errorf("toolchain inheritance cycle detected: %s", strings.Join(append(path, toolchainName), " -> "))
+		}
+	}
+
+	tc, toolchainDir, err := w.readToolchain(toolchainName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if tc.Extends != "" {
+		parent, parentDir, err := w.loadToolchain(ctx, tc.Extends, append(path, toolchainName))
+		if err != nil {
+			return nil, "", fmt.Errorf("toolchain %s extends %s: %w", toolchainName, tc.Extends, err)
+		}
+		tc = mergeToolchain(parent, parentDir, tc)
+	}
+
+	return tc, toolchainDir, nil
+}
+
+// HostToolchainKey returns the cmake_toolchain key for the current host, e.g. host-linux-x64.
+func HostToolchainKey() string {
+	return fmt.Sprintf("host-%s-%s", host.DetectHostPlatform().StringLower(), host.DetectHostProcessor().StringLower())
+}
+
 func (w *WorkspaceContext) ToolchainFilePath(ctx context.Context, modConfig *TargetConfiguration, bp TargetBuildParameters) (string, error) {
 	tc, tcPath, err := w.LoadToolchain(ctx, bp.Toolchain)
 	if err != nil {
 		return "", fmt.Errorf("failed to load toolchain: %w", err)
 	}
 
-	hostPlatform := fmt.Sprintf("host-%s-%s", host.DetectHostPlatform().StringLower(), host.DetectHostProcessor().StringLower())
+	hostPlatform := HostToolchainKey()
 	if tcf, ok := tc.CMakeToolchain[hostPlatform]; ok {
 		var tcfPath string
 		if tcf.Generate != nil {
 			tcfPath = filepath.Join(w.WorkspacePath, "buildspaces", bp.Toolchain, "generated_toolchain.cmake")
 		} else {
-			tcfPath = filepath.Join(tcPath, tcf.CMakeToolchainFile)
+			tcfPath = tcf.ResolveToolchainFile(tcPath)
 		}
 
 		absTcfPath, err := filepath.Abs(tcfPath)
@@ -144,14 +224,19 @@ func (w *WorkspaceContext) Prebuild(ctx context.Context, bp TargetBuildParameter
 		return "", fmt.Errorf("failed to load toolchain: %w", err)
 	}
 
-	hostPlatform := fmt.Sprintf("host-%s-%s", host.DetectHostPlatform().StringLower(), host.DetectHostProcessor().StringLower())
+	hostPlatform := HostToolchainKey()
 	if tcf, ok := tc.CMakeToolchain[hostPlatform]; ok {
 		tcfPath, err := w.ToolchainFilePath(ctx, nil, bp)
 		if err != nil {
 			return "", err
 		}
 		if tcf.Generate != nil {
-			err := w.GenerateToolchainFile(ctx, tcf.Generate, tc.TargetSystem, tc.TargetArch, tcfPath)
+			err := w.CheckCompilers(ctx, bp.Toolchain, tcf.Generate)
+			if err != nil {
+				return "", err
+			}
+
+			err = w.GenerateToolchainFile(ctx, tcf.Generate, w.ConfigurationDefinitions(tc), w.CompilerLauncher(tc), tc.TargetSystem, tc.TargetArch, tcfPath)
 			if err != nil {
 				return "", fmt.Errorf("failed to generate toolchain file: %w", err)
 			}
@@ -162,73 +247,215 @@ func (w *WorkspaceContext) Prebuild(ctx context.Context, bp TargetBuildParameter
 }
 
 func (w *WorkspaceContext) Build(ctx context.Context, bp TargetBuildParameters) error {
-	_, err := w.Prebuild(ctx, bp)
-	if err != nil {
-		return err
-	}
+	return w.buildTargets(ctx, w.ListTargets(ctx), bp)
+}
 
-	var builtModules = make(map[string]bool)
+func (w *WorkspaceContext) BuildTarget(ctx context.Context, targetName string, bp TargetBuildParameters) error {
+	return w.buildTargets(ctx, []string{targetName}, bp)
+}
 
-	for name := range w.Config.Targets {
-		mod, err := w.GetTarget(ctx, name)
-		if err != nil {
-			return err
-		}
-		err = w.buildModule(ctx, mod, name, builtModules, bp)
+func (w *WorkspaceContext) BuildDependencies(ctx context.Context, targetName string, bp TargetBuildParameters) error {
+	return w.BuildSelected(ctx, []string{targetName}, BuildSelection{DepsOnly: true}, bp)
+}
+
+// BuildSelection selects which targets to build relative to a set of roots.
+// By default the roots and their transitive dependencies are built.
+type BuildSelection struct {
+	// Dependents also selects every target that transitively depends on a root.
+	Dependents bool
+	// NoDeps builds only the selected targets, not their other dependencies.
+	NoDeps bool
+	// DepsOnly builds only the dependencies of the selected targets.
+	DepsOnly bool
+}
+
+// SelectTargets returns the targets selected by sel, in build order.
+func (w *WorkspaceContext) SelectTargets(ctx context.Context, roots []string, sel BuildSelection) ([]string, error) {
+	if sel.NoDeps && sel.DepsOnly {
+		return nil, fmt.Errorf("cannot build only the selected targets and only their dependencies at once")
+	}
+
+	selected := append([]string{}, roots...)
+	if sel.Dependents {
+		dependents, err := w.TargetDependents(ctx, roots)
 		if err != nil {
-			return fmt.Errorf("failed to build module %s: %w", name, err)
+			return nil, err
 		}
+		selected = append(selected, dependents...)
 	}
 
-	return nil
+	order, err := w.TargetBuildOrder(ctx, selected)
+	if err != nil {
+		return nil, err
+	}
+	if !sel.NoDeps && !sel.DepsOnly {
+		return order, nil
+	}
+
+	isSelected := make(map[string]bool)
+	for _, name := range selected {
+		isSelected[name] = true
+	}
+	var filtered []string
+	for _, name := range order {
+		if isSelected[name] == sel.NoDeps {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered, nil
 }
 
-func (w *WorkspaceContext) BuildTarget(ctx context.Context, targetName string, bp TargetBuildParameters) error {
-	_, err := w.Prebuild(ctx, bp)
+// BuildSelected builds the targets selected by sel in dependency order.
+func (w *WorkspaceContext) BuildSelected(ctx context.Context, roots []string, sel BuildSelection, bp TargetBuildParameters) error {
+	order, err := w.SelectTargets(ctx, roots, sel)
 	if err != nil {
 		return err
 	}
+	return w.buildOrdered(ctx, order, bp)
+}
 
-	var builtModules = make(map[string]bool)
-
-	mod, err := w.GetTarget(ctx, targetName)
+// buildTargets builds the given targets and their dependencies in dependency
+// order.
+func (w *WorkspaceContext) buildTargets(ctx context.Context, roots []string, bp TargetBuildParameters) error {
+	order, err := w.TargetBuildOrder(ctx, roots)
 	if err != nil {
 		return err
 	}
-
-	return w.buildModule(ctx, mod, targetName, builtModules, bp)
+	return w.buildOrdered(ctx, order, bp)
 }
 
-func (w *WorkspaceContext) BuildDependencies(ctx context.Context, targetName string, bp TargetBuildParameters) error {
+// buildOrdered builds targets in the given order, which must place every
+// target after its dependencies, recording the outcome of each target in the
+// build state.
+func (w *WorkspaceContext) buildOrdered(ctx context.Context, order []string, bp TargetBuildParameters) error {
 	_, err := w.Prebuild(ctx, bp)
 	if err != nil {
 		return err
 	}
 
-	var builtModules = make(map[string]bool)
+	launcher, launcherEnv, err := w.buildLauncher(ctx, bp)
+	if err != nil {
+		return err
+	}
+	if launcher != "" && launcherKind(launcher) != "" && !bp.DryRun {
+		before, err := ReadLauncherStats(ctx, launcher, launcherEnv)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		} else {
+			defer reportLauncherStats(ctx, launcher, launcherEnv, before)
+		}
+	}
 
-	mod, err := w.GetTarget(ctx, targetName)
+	unsupported, err := w.UnsupportedTargets(ctx, order, bp)
 	if err != nil {
 		return err
 	}
+	supported := make([]string, 0, len(order))
+	for _, name := range order {
+		if reason, ok := unsupported[name]; ok {
+			fmt.Printf("Skipping %s: %s\n", name, reason)
+			continue
+		}
+		supported = append(supported, name)
+	}
+	order = supported
+
+	if w.State != nil && !bp.Resume && !bp.OnlyFailed {
+		for _, name := range order {
+			w.State.SetStatus(bp, name, BuildStatusPending)
+		}
+	}
+
+	stagingCache, err := w.StagingCache()
+	if err != nil {
+		return err
+	}
+	cacheKeys := make(map[string]string)
+
+	rebuilt := make(map[string]bool)
+
+	for _, name := range order {
+		mod, err := w.GetTarget(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		skip, err := w.skipTarget(ctx, mod, rebuilt, bp)
+		if err != nil {
+			return err
+		}
+		if skip {
+			continue
+		}
 
-	for _, dep := range mod.Config.Depends {
-		parts := strings.SplitN(dep, "/", 2)
-		depTargetName := parts[0]
-		depMod, err := w.GetTarget(ctx, depTargetName)
+		restored, err := w.restoreFromCache(ctx, stagingCache, mod, bp, cacheKeys)
 		if err != nil {
 			return err
 		}
-		err = w.buildModule(ctx, depMod, depTargetName, builtModules, bp)
+
+		if !restored {
+			err = w.buildModule(ctx, mod, name, bp, launcherEnv)
+			if err != nil {
+				if stateErr := w.recordStatus(ctx, bp, name, BuildStatusFailed); stateErr != nil {
+					fmt.Printf("Warning: %v\n", stateErr)
+				}
+				return fmt.Errorf("failed to build module %s: %w", name, err)
+			}
+
+			err = w.storeInCache(ctx, stagingCache, mod, bp, cacheKeys)
+			if err != nil {
+				fmt.Printf("Warning: failed to cache %s: %v\n", name, err)
+			}
+		}
+
+		err = w.recordStatus(ctx, bp, name, BuildStatusSucceeded)
 		if err != nil {
-			return fmt.Errorf("failed to build dependency %s: %w", depTargetName, err)
+			return err
 		}
+		rebuilt[name] = true
 	}
 
 	return nil
 }
 
-func (w *WorkspaceContext) CleanTarget(ctx context.Context, targetName string, bp TargetBuildParameters) error {
+// skipTarget reports whether a target can be skipped when resuming or retrying
+// a previous build. A target is never skipped if one of its dependencies was
+// rebuilt during this run.
+func (w *WorkspaceContext) skipTarget(ctx context.Context, mod *TargetContext, rebuilt map[string]bool, bp TargetBuildParameters) (bool, error) {
+	if w.State == nil || (!bp.Resume && !bp.OnlyFailed) {
+		return false, nil
+	}
+
+	deps, err := w.TargetDependencies(ctx, mod.Name)
+	if err != nil {
+		return false, err
+	}
+	for _, dep := range deps {
+		if rebuilt[dep] {
+			return false, nil
+		}
+	}
+
+	status := w.State.Status(bp, mod.Name)
+	if bp.Resume && status != BuildStatusSucceeded {
+		return false, nil
+	}
+	if bp.OnlyFailed && status == BuildStatusFailed {
+		return false, nil
+	}
+
+	fmt.Printf("Skipping %s (%s in previous build)\n", mod.Name, status)
+	return true, nil
+}
+
+// CleanOptions selects which outputs CleanTarget removes in addition to the
+// target's build tree.
+type CleanOptions struct {
+	Staging bool
+	Exports bool
+}
+
+func (w *WorkspaceContext) CleanTarget(ctx context.Context, targetName string, bp TargetBuildParameters, opts CleanOptions) error {
 	mod, err := w.GetTarget(ctx, targetName)
 	if err != nil {
 		return err
@@ -238,16 +465,62 @@ func (w *WorkspaceContext) CleanTarget(ctx context.Context, targetName string, b
 	if err != nil {
 		return err
 	}
+	paths := []string{buildPath}
+
+	if opts.Staging {
+		stagingPath, err := mod.CMakeStagingPath(ctx, w, bp)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, stagingPath)
+	}
+
+	if opts.Exports {
+		exportPath, err := mod.CMakeExportPath(ctx, w, bp)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, exportPath)
+	}
 
-	if bp.DryRun {
-		fmt.Printf("dry-run: would delete build path %s\n", buildPath)
+	for _, path := range paths {
+		err = w.removePath(path, bp.DryRun)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The target has to be built again, even when resuming.
+	return w.recordStatus(ctx, bp, targetName, BuildStatusPending)
+}
+
+// CleanToolchain removes the toolchain file generated for a toolchain.
+func (w *WorkspaceContext) CleanToolchain(ctx context.Context, bp TargetBuildParameters) error {
+	return w.removePath(filepath.Join(w.WorkspacePath, "buildspaces", bp.Toolchain, "generated_toolchain.cmake"), bp.DryRun)
+}
+
+func (w *WorkspaceContext) removePath(path string, dryRun bool) error {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
 		return nil
 	}
-	return os.RemoveAll(buildPath)
+	if dryRun {
+		fmt.Printf("dry-run: would delete %s\n", path)
+		return nil
+	}
+	return os.RemoveAll(path)
 }
 
 func (w *WorkspaceContext) Exec(ctx context.Context, command string, args []string, dryRun bool) error {
-	fmt.Printf("Executing: %s", command)
+	return w.ExecEnv(ctx, command, args, nil, dryRun)
+}
+
+// ExecEnv is like Exec, with env applied on top of the current environment.
+func (w *WorkspaceContext) ExecEnv(ctx context.Context, command string, args []string, env RuntimeEnvironment, dryRun bool) error {
+	fmt.Printf("Executing:")
+	for _, v := range env {
+		fmt.Printf(" %s=%s", v.Name, v.Value(""))
+	}
+	fmt.Printf(" %s", command)
 	for _, arg := range args {
 		fmt.Printf(" %s", arg)
 	}
@@ -258,30 +531,15 @@ func (w *WorkspaceContext) Exec(ctx context.Context, command string, args []stri
 	}
 
 	cmd := exec.CommandContext(ctx, command, args...)
+	if len(env) != 0 {
+		cmd.Env = env.Environ(os.Environ())
+	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
-func (w *WorkspaceContext) buildModule(ctx context.Context, mod *TargetContext, modname string, builtModules map[string]bool, bp TargetBuildParameters) error {
-
-	if builtModules[modname] {
-		return nil
-	}
-
-	for _, dep := range mod.Config.Depends {
-		parts := strings.SplitN(dep, "/", 2)
-		targetName := parts[0]
-		depMod, err := w.GetTarget(ctx, targetName)
-		if err != nil {
-			return err
-		}
-		err = w.buildModule(ctx, depMod, targetName, builtModules, bp)
-		if err != nil {
-			return fmt.Errorf("failed to build dependency %s: %w", targetName, err)
-		}
-	}
-
+func (w *WorkspaceContext) buildModule(ctx context.Context, mod *TargetContext, modname string, bp TargetBuildParameters, launcherEnv RuntimeEnvironment) error {
 	cmakeBinary := "cmake"
 	if w.Config.CMakeBinary != nil {
 		cmakeBinary = *w.Config.CMakeBinary
@@ -296,11 +554,6 @@ func (w *WorkspaceContext) buildModule(ctx context.Context, mod *TargetContext,
 		return fmt.Errorf("failed to get cmake configure args: %w", err)
 	}
 
-	err = w.Exec(ctx, cmakeBinary, cMakeConfigureArgs, bp.DryRun)
-	if err != nil {
-		return fmt.Errorf("failed to configure module %s: %w", modname, err)
-	}
-
 	buildPath, err := mod.CMakeBuildPath(ctx, w, bp)
 	if err != nil {
 		return fmt.Errorf("failed to get build path: %w", err)
@@ -310,10 +563,31 @@ func (w *WorkspaceContext) buildModule(ctx context.Context, mod *TargetContext,
 		return fmt.Errorf("failed to get absolute build path: %w", err)
 	}
 
+	// Ask CMake to describe the build tree's targets, used by cbuild run.
+	if !bp.DryRun {
+		err = cmake.WriteFileAPIQuery(buildPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	// pkg_check_modules runs at configure time, and again whenever CMake
+	// reconfigures during the build.
+	pkgConfigEnv, err := w.PkgConfigEnvironment(ctx, mod, bp)
+	if err != nil {
+		return fmt.Errorf("failed to get pkg-config environment: %w", err)
+	}
+	buildEnv := append(pkgConfigEnv, launcherEnv...)
+
+	err = w.ExecEnv(ctx, cmakeBinary, cMakeConfigureArgs, buildEnv, bp.DryRun)
+	if err != nil {
+		return fmt.Errorf("failed to configure module %s: %w", modname, err)
+	}
+
 	// Build the module
 	buildCmd := []string{"--build", buildPath, "--config", bp.BuildType}
 
-	err = w.Exec(ctx, cmakeBinary, buildCmd, bp.DryRun)
+	err = w.ExecEnv(ctx, cmakeBinary, buildCmd, buildEnv, bp.DryRun)
 	if err != nil {
 		return fmt.Errorf("failed to build module %s: %w", modname, err)
 	}
@@ -333,9 +607,21 @@ func (w *WorkspaceContext) buildModule(ctx context.Context, mod *TargetContext,
 		if err != nil {
 			return fmt.Errorf("failed to install module %s to staging: %w", modname, err)
 		}
-	}
 
-	builtModules[modname] = true
+		if !bp.DryRun {
+			buildspacesPath, err := filepath.Abs(filepath.Join(w.WorkspacePath, "buildspaces"))
+			if err != nil {
+				return err
+			}
+			warnings, err := CheckPkgConfigFiles(stagingPath, buildspacesPath)
+			if err != nil {
+				return fmt.Errorf("failed to check pkg-config files of %s: %w", modname, err)
+			}
+			for _, warning := range warnings {
+				fmt.Printf("Warning: %s\n", warning)
+			}
+		}
+	}
 
 	return nil
 }
@@ -510,6 +796,7 @@ func (ws *WorkspaceContext) ListTargets(ctx context.Context) []string {
 	for k, _ := range ws.Config.Targets {
 		targets = append(targets, k)
 	}
+	sort.Strings(targets)
 	return targets
 }
 
@@ -731,7 +1018,28 @@ func (w *WorkspaceContext) SetStaging(ctx context.Context, source string, enable
 	return nil
 }
 
+func (w *WorkspaceContext) SetExportCompileCommands(ctx context.Context, enabled bool) error {
+	w.Config.ExportCompileCommands = enabled
+
+	err := w.Save(ctx)
+	if err != nil {
+		return err
+	}
+
+	if enabled {
+		fmt.Println("Enabled compile_commands.json export for all targets")
+	} else {
+		fmt.Println("Disabled compile_commands.json export")
+	}
+	return nil
+}
+
 func (w *WorkspaceContext) AddConfiguration(ctx context.Context, configName string) error {
+	err := ValidateConfigurationName(configName)
+	if err != nil {
+		return err
+	}
+
 	for _, cfg := range w.Config.Configurations {
 		if cfg == configName {
 			fmt.Printf("Configuration %s already exists\n", configName)
@@ -740,7 +1048,7 @@ func (w *WorkspaceContext) AddConfiguration(ctx context.Context, configName stri
 	}
 
 	w.Config.Configurations = append(w.Config.Configurations, configName)
-	err := w.Save(ctx)
+	err = w.Save(ctx)
 	if err != nil {
 		return err
 	}
@@ -765,6 +1073,7 @@ func (w *WorkspaceContext) RemoveConfiguration(ctx context.Context, configName s
 	}
 
 	w.Config.Configurations = newConfigs
+	delete(w.Config.ConfigurationDefinitions, configName)
 	err := w.Save(ctx)
 	if err != nil {
 		return err
@@ -774,24 +1083,111 @@ func (w *WorkspaceContext) RemoveConfiguration(ctx context.Context, configName s
 	return nil
 }
 
-func (ws *WorkspaceContext) DetectToolchains(ctx context.Context) error {
+// DetectOptions configures toolchain detection.
+type DetectOptions struct {
+	/// Directories to search for compilers before PATH.
+	SearchDirs []string
+
+	/// Detect ccache or sccache and set it as the compiler launcher of the
+	/// detected toolchains.
+	DetectLauncher bool
+}
+
+func (ws *WorkspaceContext) DetectToolchains(ctx context.Context, opts DetectOptions) error {
 	hostOS := host.DetectHostPlatform()
 	hostProcessor := host.DetectHostProcessor()
 	hostKey := fmt.Sprintf("host-%s-%s", hostOS.StringLower(), hostProcessor.StringLower())
 
-	targetSystem := hostOS
-	targetArch := hostProcessor
-
-	detectors := []struct {
+	type detector struct {
 		name          string
 		cCompiler     string
 		cxxCompiler   string
 		extraCXXFlags []string
-	}{
-		{"system-gcc", "gcc", "g++", nil},
-		{"system-clang", "clang", "clang++", nil},
-		{"system-clang-libcxx", "clang", "clang++", []string{"-stdlib=libc++"}},
-		{"system-gcc-libcxx", "gcc", "g++", []string{"-stdlib=libc++"}},
+		linkerType    cmake.LinkerType
+		targetSystem  system.Platform
+		targetArch    system.Processor
+	}
+
+	searchDirs := append([]string{}, opts.SearchDirs...)
+	if ws.UserConfig != nil {
+		searchDirs = append(searchDirs, ws.UserConfig.CompilerSearchDirs...)
+	}
+
+	var compilers []detector
+	for _, c := range FindCompilers(CompilerSearchDirs(searchDirs)) {
+		if c.Family == "gcc" {
+			isGCCReal, err := GCCIsRealGCC(c.CCompiler)
+			if err != nil || !isGCCReal {
+				continue
+			}
+		}
+
+		triple, err := DumpMachine(c.CCompiler)
+		if err != nil {
+			fmt.Printf("Detected %s, but could not determine its target, skipping.\n", c.Name)
+			continue
+		}
+		targetSystem, targetArch, err := system.ParseTriple(triple)
+		if err != nil {
+			fmt.Printf("Detected %s, but its target %s is not supported, skipping.\n", c.Name, triple)
+			continue
+		}
+
+		// Triple-prefixed compilers for the host are the system compilers
+		// under another name.
+		if c.Triple != "" && targetSystem == hostOS && targetArch == hostProcessor {
+			continue
+		}
+
+		d := detector{
+			name:         c.Name,
+			cCompiler:    c.CCompiler,
+			cxxCompiler:  c.CXXCompiler,
+			targetSystem: targetSystem,
+			targetArch:   targetArch,
+		}
+		compilers = append(compilers, d)
+
+		if hostOS != system.PlatformMac {
+			d.name = c.Name + "-libcxx"
+			d.extraCXXFlags = []string{"-stdlib=libc++"}
+			compilers = append(compilers, d)
+		}
+	}
+
+	if len(compilers) == 0 {
+		fmt.Println("No compilers found.")
+		return nil
+	}
+
+	// Each compiler is also tried with every alternative linker that is
+	// installed, the hello world build below checks that it is usable.
+	var linkers []cmake.LinkerType
+	for _, linkerType := range []cmake.LinkerType{cmake.LinkerTypeLLD, cmake.LinkerTypeMold, cmake.LinkerTypeGold} {
+		if _, err := exec.LookPath(linkerType.LinkerBinary()); err == nil {
+			linkers = append(linkers, linkerType)
+		}
+	}
+
+	var detectors []detector
+	for _, c := range compilers {
+		detectors = append(detectors, c)
+		for _, linkerType := range linkers {
+			d := c
+			d.name = fmt.Sprintf("%s-%s", c.name, linkerType)
+			d.linkerType = linkerType
+			detectors = append(detectors, d)
+		}
+	}
+
+	launcher := ""
+	if opts.DetectLauncher {
+		launcher = DetectCompilerLauncher()
+		if launcher != "" {
+			fmt.Printf("Detected compiler launcher %s.\n", launcher)
+		} else {
+			fmt.Println("Neither ccache nor sccache found, toolchains are created without a compiler launcher.")
+		}
 	}
 
 	toolchainsDir := filepath.Join(ws.WorkspacePath, "toolchains")
@@ -801,117 +1197,112 @@ func (ws *WorkspaceContext) DetectToolchains(ctx context.Context) error {
 	}
 
 	for _, d := range detectors {
-		_, errC := exec.LookPath(d.cCompiler)
-		_, errCXX := exec.LookPath(d.cxxCompiler)
-
-		if errC == nil && errCXX == nil {
-
-			if hostOS == system.PlatformMac && strings.Contains(d.name, "libcxx") {
-				continue
-			}
-
-			if d.cCompiler == "gcc" {
-				isGCCReal, err := GCCIsRealGCC(d.cCompiler)
-				if err != nil {
-					return fmt.Errorf("error checking GCCIsRealGCC: %w", err)
-				}
-				if !isGCCReal {
-					continue
-				}
-			}
-			// Check if compilers actually work by building a minimal CMake project
-			testDir, err := os.MkdirTemp("", "csetup_detect_test")
-			if err != nil {
-				return fmt.Errorf("failed to create temp dir: %w", err)
-			}
-			defer os.RemoveAll(testDir)
+		// Check if compilers actually work by building a minimal CMake project
+		testDir, err := os.MkdirTemp("", "csetup_detect_test")
+		if err != nil {
+			return fmt.Errorf("failed to create temp dir: %w", err)
+		}
+		defer os.RemoveAll(testDir)
 
-			err = os.MkdirAll(filepath.Join(testDir, "build"), 0755)
-			if err != nil {
-				return fmt.Errorf("failed to create build dir: %w", err)
-			}
+		err = os.MkdirAll(filepath.Join(testDir, "build"), 0755)
+		if err != nil {
+			return fmt.Errorf("failed to create build dir: %w", err)
+		}
 
-			err = os.WriteFile(filepath.Join(testDir, "CMakeLists.txt"), []byte("cmake_minimum_required(VERSION 3.10)\nproject(test)\nadd_executable(test main.cpp)\n"), 0644)
-			if err != nil {
-				return fmt.Errorf("failed to create CMakeLists.txt: %w", err)
-			}
+		err = os.WriteFile(filepath.Join(testDir, "CMakeLists.txt"), []byte("cmake_minimum_required(VERSION 3.10)\nproject(test)\nadd_executable(test main.cpp)\n"), 0644)
+		if err != nil {
+			return fmt.Errorf("failed to create CMakeLists.txt: %w", err)
+		}
 
-			err = os.WriteFile(filepath.Join(testDir, "main.cpp"), []byte("int main() { return 0; }\n"), 0644)
-			if err != nil {
-				return fmt.Errorf("failed to create main.cpp: %w", err)
-			}
+		err = os.WriteFile(filepath.Join(testDir, "main.cpp"), []byte("int main() { return 0; }\n"), 0644)
+		if err != nil {
+			return fmt.Errorf("failed to create main.cpp: %w", err)
+		}
 
-			// Generate a temporary toolchain file for the test
-			tcFilePath := filepath.Join(testDir, "toolchain.cmake")
-			tc := Toolchain{
-				TargetArch:   targetArch,
-				TargetSystem: targetSystem,
-				CMakeToolchain: map[string]CMakeToolchainOptions{
-					hostKey: {
-						Generate: &CMakeGenerateToolchainFileOptions{
-							CCompiler:     d.cCompiler,
-							CXXCompiler:   d.cxxCompiler,
-							ExtraCXXFlags: d.extraCXXFlags,
-						},
+		// Generate a temporary toolchain file for the test
+		tcFilePath := filepath.Join(testDir, "toolchain.cmake")
+		tc := Toolchain{
+			TargetArch:   d.targetArch,
+			TargetSystem: d.targetSystem,
+			CMakeToolchain: map[string]CMakeToolchainOptions{
+				hostKey: {
+					Generate: &CMakeGenerateToolchainFileOptions{
+						CCompiler:     d.cCompiler,
+						CXXCompiler:   d.cxxCompiler,
+						ExtraCXXFlags: d.extraCXXFlags,
+						LinkerType:    d.linkerType,
 					},
 				},
-			}
+			},
+		}
 
-			// We need a workspace to call GenerateToolchainFile, but we can call cmake.GenerateToolchainFile directly
-			err = ws.GenerateToolchainFile(ctx, tc.CMakeToolchain[hostKey].Generate, targetSystem, targetArch, tcFilePath)
-			if err != nil {
-				return fmt.Errorf("failed to generate test toolchain file: %w", err)
-			}
+		// We need a workspace to call GenerateToolchainFile, but we can call cmake.GenerateToolchainFile directly
+		err = ws.GenerateToolchainFile(ctx, tc.CMakeToolchain[hostKey].Generate, nil, "", d.targetSystem, d.targetArch, tcFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to generate test toolchain file: %w", err)
+		}
 
-			// Run CMake configure
-			cmd := exec.CommandContext(ctx, "cmake", "-S", testDir, "-B", filepath.Join(testDir, "build"), "-G", "Ninja", "-DCMAKE_TOOLCHAIN_FILE="+tcFilePath)
-			err = cmd.Run()
-			if err != nil {
-				fmt.Printf("Detected %s, but %s cannot build a hello world program, skipping.\n", d.cxxCompiler, d.name)
-				continue
-			}
+		// Run CMake configure
+		cmd := exec.CommandContext(ctx, "cmake", "-S", testDir, "-B", filepath.Join(testDir, "build"), "-G", "Ninja", "-DCMAKE_TOOLCHAIN_FILE="+tcFilePath)
+		err = cmd.Run()
+		if err != nil {
+			fmt.Printf("Detected %s, but %s cannot build a hello world program, skipping.\n", d.cxxCompiler, d.name)
+			continue
+		}
 
-			// Run CMake build
-			cmd = exec.CommandContext(ctx, "cmake", "--build", filepath.Join(testDir, "build"))
-			err = cmd.Run()
-			if err != nil {
-				fmt.Printf("Detected %s, but %s cannot build a hello world program, skipping.\n", d.cxxCompiler, d.name)
-				continue
-			}
+		// Run CMake build
+		cmd = exec.CommandContext(ctx, "cmake", "--build", filepath.Join(testDir, "build"))
+		err = cmd.Run()
+		if err != nil {
+			fmt.Printf("Detected %s, but %s cannot build a hello world program, skipping.\n", d.cxxCompiler, d.name)
+			continue
+		}
 
-			fmt.Printf("Detected %s, creating toolchain...\n", d.name)
-
-			finalTc := Toolchain{
-				TargetArch:   targetArch,
-				TargetSystem: targetSystem,
-				CMakeToolchain: map[string]CMakeToolchainOptions{
-					hostKey: {
-						Generate: &CMakeGenerateToolchainFileOptions{
-							CCompiler:     d.cCompiler,
-							CXXCompiler:   d.cxxCompiler,
-							ExtraCXXFlags: d.extraCXXFlags,
-						},
+		cID, err := ws.identifyCompiler(d.cCompiler)
+		if err != nil {
+			fmt.Printf("Detected %s, but failed to identify %s, skipping: %v\n", d.name, d.cCompiler, err)
+			continue
+		}
+		cxxID, err := ws.identifyCompiler(d.cxxCompiler)
+		if err != nil {
+			fmt.Printf("Detected %s, but failed to identify %s, skipping: %v\n", d.name, d.cxxCompiler, err)
+			continue
+		}
+
+		fmt.Printf("Detected %s, creating toolchain...\n", d.name)
+
+		finalTc := Toolchain{
+			TargetArch:       d.targetArch,
+			TargetSystem:     d.targetSystem,
+			CompilerLauncher: launcher,
+			CMakeToolchain: map[string]CMakeToolchainOptions{
+				hostKey: {
+					Generate: &CMakeGenerateToolchainFileOptions{
+						CCompiler:           d.cCompiler,
+						CXXCompiler:         d.cxxCompiler,
+						ExtraCXXFlags:       d.extraCXXFlags,
+						LinkerType:          d.linkerType,
+						CCompilerIdentity:   cID,
+						CXXCompilerIdentity: cxxID,
 					},
 				},
-			}
+			},
+		}
 
-			tcDir := filepath.Join(toolchainsDir, d.name)
-			err = os.MkdirAll(tcDir, 0755)
-			if err != nil {
-				return fmt.Errorf("failed to create toolchain directory for %s: %w", d.name, err)
-			}
+		tcDir := filepath.Join(toolchainsDir, d.name)
+		err = os.MkdirAll(tcDir, 0755)
+		if err != nil {
+			return fmt.Errorf("failed to create toolchain directory for %s: %w", d.name, err)
+		}
 
-			yamlFile, err := yaml.Marshal(finalTc)
-			if err != nil {
-				return fmt.Errorf("failed to marshal toolchain %s: %w", d.name, err)
-			}
+		yamlFile, err := yaml.Marshal(finalTc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal toolchain %s: %w", d.name, err)
+		}
 
-			err = os.WriteFile(filepath.Join(tcDir, "toolchain.yml"), yamlFile, 0644)
-			if err != nil {
-				return fmt.Errorf("failed to write toolchain file for %s: %w", d.name, err)
-			}
-		} else {
-			fmt.Printf("Compilers for %s not found (tried %s and %s), skipping.\n", d.name, d.cCompiler, d.cxxCompiler)
+		err = os.WriteFile(filepath.Join(tcDir, "toolchain.yml"), yamlFile, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to write toolchain file for %s: %w", d.name, err)
 		}
 	}
 
@@ -0,0 +1,77 @@
+package ccommon
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// buildSelectionWorkspace has the graph
+//
+//	app -> lib -> base
+//	tool -> lib
+//	other
+func buildSelectionWorkspace() *WorkspaceContext {
+	return &WorkspaceContext{
+		Config: WorkspaceConfig{
+			Targets: map[string]*TargetConfiguration{
+				"base":  {},
+				"lib":   {Depends: []string{"base"}},
+				"app":   {Depends: []string{"lib"}},
+				"tool":  {Depends: []string{"lib"}},
+				"other": {},
+			},
+		},
+	}
+}
+
+func TestSelectTargets(t *testing.T) {
+	w := buildSelectionWorkspace()
+
+	tests := []struct {
+		name  string
+		roots []string
+		sel   BuildSelection
+		want  []string
+	}{
+		{"default", []string{"app"}, BuildSelection{}, []string{"base", "lib", "app"}},
+		{"leaf", []string{"base"}, BuildSelection{}, []string{"base"}},
+		{"no deps", []string{"app"}, BuildSelection{NoDeps: true}, []string{"app"}},
+		{"deps only", []string{"app"}, BuildSelection{DepsOnly: true}, []string{"base", "lib"}},
+		{"deps only of leaf", []string{"base"}, BuildSelection{DepsOnly: true}, nil},
+		{"rdeps", []string{"lib"}, BuildSelection{Dependents: true}, []string{"base", "lib", "app", "tool"}},
+		{"rdeps of leaf", []string{"app"}, BuildSelection{Dependents: true}, []string{"base", "lib", "app"}},
+		{"rdeps no deps", []string{"lib"}, BuildSelection{Dependents: true, NoDeps: true}, []string{"lib", "app", "tool"}},
+		{"rdeps deps only", []string{"lib"}, BuildSelection{Dependents: true, DepsOnly: true}, []string{"base"}},
+		{"multiple roots", []string{"app", "other"}, BuildSelection{NoDeps: true}, []string{"app", "other"}},
+		{"root is dependency of root", []string{"app", "lib"}, BuildSelection{DepsOnly: true}, []string{"base"}},
+	}
+
+	for _, tt := range tests {
+		got, err := w.SelectTargets(context.Background(), tt.roots, tt.sel)
+		if err != nil {
+			t.Errorf("%s: SelectTargets(%v, %+v) error = %v", tt.name, tt.roots, tt.sel, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: SelectTargets(%v, %+v) = %v, want %v", tt.name, tt.roots, tt.sel, got, tt.want)
+		}
+	}
+}
+
+func TestSelectTargetsErrors(t *testing.T) {
+	w := buildSelectionWorkspace()
+
+	for _, tt := range []struct {
+		roots []string
+		sel   BuildSelection
+	}{
+		{[]string{"app"}, BuildSelection{NoDeps: true, DepsOnly: true}},
+		{[]string{"missing"}, BuildSelection{}},
+		{[]string{"missing"}, BuildSelection{Dependents: true}},
+	} {
+		if got, err := w.SelectTargets(context.Background(), tt.roots, tt.sel); err == nil {
+			t.Errorf("SelectTargets(%v, %+v) = %v, want error", tt.roots, tt.sel, got)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"gitlab.com/rpnx/cbuild-go/pkg/system"
@@ -22,12 +23,26 @@ const (
 	CompilerTypeMSVC
 )
 
+func (c CompilerType) String() string {
+	switch c {
+	case CompilerTypeGCC:
+		return "GCC"
+	case CompilerTypeClang:
+		return "Clang"
+	case CompilerTypeMSVC:
+		return "MSVC"
+	}
+	return "Unknown"
+}
+
 type LinkerType int
 
 const (
 	LinkerTypeUnknown LinkerType = iota
 	LinkerTypeGNULD
 	LinkerTypeLLD
+	LinkerTypeGold
+	LinkerTypeMold
 )
 
 type BuildPreset int
@@ -47,17 +62,30 @@ const (
 )
 
 type GenerateToolchainFileOptions struct {
-	CompilerType       CompilerType
-	CCompiler          string
-	CXXCompiler        string
-	Linker             string
-	ExtraCompilerFlags []string
-	ExtraCFlags        []string
-	ExtraCXXFlags      []string
-	SystemPlatform     system.Platform
-	SystemProcessor    system.Processor
-	WorkspaceDir       string
-	OutputFile         string
+	CompilerType           CompilerType
+	CCompiler              string
+	CXXCompiler            string
+	Linker                 string
+	LinkerType             LinkerType
+	ExtraCompilerFlags     []string
+	ExtraCFlags            []string
+	ExtraCXXFlags          []string
+	ExtraLinkerFlags       []string
+	ExtraSharedLinkerFlags []string
+	ExtraExeLinkerFlags    []string
+	SystemPlatform         system.Platform
+	SystemProcessor        system.Processor
+	Sysroot                string
+	TargetTriple           string
+	FindRootPath           []string
+	FindRootPathModes      *FindRootPathModes
+	CompilerLauncher       string
+	WorkspaceDir           string
+	OutputFile             string
+
+	// Configurations defines configurations in addition to, or extending, the
+	// built-in ones.
+	Configurations map[string]*ConfigurationDefinition
 }
 
 func PlatformToCMakeName(platform system.Platform) (string, error) {
@@ -204,6 +232,13 @@ func GenerateToolchainFile(ctx context.Context, opts GenerateToolchainFileOption
 	if linker != "" {
 		sb.WriteString(fmt.Sprintf("set(CMAKE_LINKER \"%s\")\n", linker))
 	}
+	sb.WriteString(compilerLauncherSettings(opts.CompilerLauncher))
+
+	cross, err := crossSettings(opts, absWorkspaceDir)
+	if err != nil {
+		return err
+	}
+	sb.WriteString(cross)
 
 	// Remap debug symbols
 	// We use -fdebug-prefix-map=OLD=NEW for GCC/Clang
@@ -222,6 +257,7 @@ func GenerateToolchainFile(ctx context.Context, opts GenerateToolchainFileOption
 	var relTSANFlags []string
 
 	supportedConfigs := []string{"Debug", "Release", "RelWithDebInfo", "Quick", "Profile"}
+	builtinLinkerFlags := make(map[string][]string)
 
 	if opts.CompilerType == CompilerTypeClang {
 		debugFlags = append(debugFlags, "-fdebug-compilation-dir=.")
@@ -265,6 +301,13 @@ func GenerateToolchainFile(ctx context.Context, opts GenerateToolchainFileOption
 		relTSANFlags = append(relTSANFlags, releaseFlags...)
 		relTSANFlags = append(relTSANFlags, TSANFlags...)
 
+		// Sanitizers and coverage need their runtime libraries at link time.
+		builtinLinkerFlags["DebugCoverage"] = []string{"--coverage"}
+		builtinLinkerFlags["DebugASAN"] = ASANFlags
+		builtinLinkerFlags["DebugTSAN"] = TSANFlags
+		builtinLinkerFlags["ReleaseASAN"] = ASANFlags
+		builtinLinkerFlags["ReleaseTSAN"] = TSANFlags
+
 		supportedConfigs = append(supportedConfigs, "DebugCoverage", "DebugASAN", "DebugTSAN", "ReleaseASAN", "ReleaseTSAN")
 	}
 
@@ -300,72 +343,72 @@ func GenerateToolchainFile(ctx context.Context, opts GenerateToolchainFileOption
 	sb.WriteString(fmt.Sprintf("set(CMAKE_C_FLAGS_INIT %q)\n", strings.Join(cFlags, " ")))
 	sb.WriteString(fmt.Sprintf("set(CMAKE_CXX_FLAGS_INIT %q)\n", strings.Join(cxxFlags, " ")))
 
-	if len(supportedConfigs) > 0 {
-		sb.WriteString(fmt.Sprintf("set(CMAKE_CONFIGURATION_TYPES %q CACHE STRING \"\" FORCE)\n", strings.Join(supportedConfigs, ";")))
-	}
-
-	// Debug
-	if contains(supportedConfigs, "Debug") {
-		sb.WriteString(fmt.Sprintf("set(CMAKE_CXX_FLAGS_DEBUG_INIT %q)\n", strings.Join(debugFlags, " ")))
-		sb.WriteString(fmt.Sprintf("set(CMAKE_C_FLAGS_DEBUG_INIT %q)\n", strings.Join(debugFlags, " ")))
-	}
-
-	// Release
-	if contains(supportedConfigs, "Release") {
-		sb.WriteString(fmt.Sprintf("set(CMAKE_CXX_FLAGS_RELEASE_INIT %q)\n", strings.Join(releaseFlags, " ")))
-		sb.WriteString(fmt.Sprintf("set(CMAKE_C_FLAGS_RELEASE_INIT %q)\n", strings.Join(releaseFlags, " ")))
-	}
-
-	// RelWithDebInfo
-	if contains(supportedConfigs, "RelWithDebInfo") {
-		sb.WriteString(fmt.Sprintf("set(CMAKE_CXX_FLAGS_RELWITHDEBINFO_INIT %q)\n", strings.Join(profileFlags, " ")))
-		sb.WriteString(fmt.Sprintf("set(CMAKE_C_FLAGS_RELWITHDEBINFO_INIT %q)\n", strings.Join(profileFlags, " ")))
-	}
+	// Modules are linked like shared libraries.
+	exeLinkerFlags := concatFlags(opts.ExtraLinkerFlags, opts.ExtraExeLinkerFlags)
+	sharedLinkerFlags := concatFlags(opts.ExtraLinkerFlags, opts.ExtraSharedLinkerFlags)
 
-	// Quick
-	if contains(supportedConfigs, "Quick") {
-		sb.WriteString(fmt.Sprintf("set(CMAKE_CXX_FLAGS_QUICK_INIT %q)\n", strings.Join(quickFlags, " ")))
-		sb.WriteString(fmt.Sprintf("set(CMAKE_C_FLAGS_QUICK_INIT %q)\n", strings.Join(quickFlags, " ")))
-	}
+	sb.WriteString(fmt.Sprintf("set(CMAKE_EXE_LINKER_FLAGS_INIT %q)\n", strings.Join(exeLinkerFlags, " ")))
+	sb.WriteString(fmt.Sprintf("set(CMAKE_SHARED_LINKER_FLAGS_INIT %q)\n", strings.Join(sharedLinkerFlags, " ")))
+	sb.WriteString(fmt.Sprintf("set(CMAKE_MODULE_LINKER_FLAGS_INIT %q)\n", strings.Join(sharedLinkerFlags, " ")))
 
-	// Profile
-	if contains(supportedConfigs, "Profile") {
-		sb.WriteString(fmt.Sprintf("set(CMAKE_CXX_FLAGS_PROFILE_INIT %q)\n", strings.Join(profileFlags, " ")))
-		sb.WriteString(fmt.Sprintf("set(CMAKE_C_FLAGS_PROFILE_INIT %q)\n", strings.Join(profileFlags, " ")))
+	selection, err := linkerSelection(opts.CompilerType, opts.LinkerType)
+	if err != nil {
+		return err
 	}
-
-	// DebugCoverage
-	if contains(supportedConfigs, "DebugCoverage") {
-		sb.WriteString(fmt.Sprintf("set(CMAKE_CXX_FLAGS_DEBUGCOVERAGE_INIT %q)\n", strings.Join(debugCoverageFlags, " ")))
-		sb.WriteString(fmt.Sprintf("set(CMAKE_C_FLAGS_DEBUGCOVERAGE_INIT %q)\n", strings.Join(debugCoverageFlags, " ")))
+	sb.WriteString(selection)
+
+	builtinFlags := map[string][]string{
+		"Debug":          debugFlags,
+		"Release":        releaseFlags,
+		"RelWithDebInfo": profileFlags,
+		"Quick":          quickFlags,
+		"Profile":        profileFlags,
+		"DebugCoverage":  debugCoverageFlags,
+		"DebugASAN":      debugASANFlags,
+		"DebugTSAN":      debugTSANFlags,
+		"ReleaseASAN":    relASANFlags,
+		"ReleaseTSAN":    relTSANFlags,
+	}
+
+	builtin := make(map[string]ConfigurationDefinition)
+	for _, config := range supportedConfigs {
+		builtin[config] = ConfigurationDefinition{
+			CFlags:      builtinFlags[config],
+			CXXFlags:    builtinFlags[config],
+			LinkerFlags: builtinLinkerFlags[config],
+		}
 	}
 
-	// DebugASAN
-	if contains(supportedConfigs, "DebugASAN") {
-		sb.WriteString(fmt.Sprintf("set(CMAKE_CXX_FLAGS_DEBUGASAN_INIT %q)\n", strings.Join(debugASANFlags, " ")))
-		sb.WriteString(fmt.Sprintf("set(CMAKE_C_FLAGS_DEBUGASAN_INIT %q)\n", strings.Join(debugASANFlags, " ")))
+	configs, err := ResolveConfigurations(builtin, opts.Configurations)
+	if err != nil {
+		return err
 	}
 
-	// DebugTSAN
-	if contains(supportedConfigs, "DebugTSAN") {
-		sb.WriteString(fmt.Sprintf("set(CMAKE_CXX_FLAGS_DEBUGTSAN_INIT %q)\n", strings.Join(debugTSANFlags, " ")))
-		sb.WriteString(fmt.Sprintf("set(CMAKE_C_FLAGS_DEBUGTSAN_INIT %q)\n", strings.Join(debugTSANFlags, " ")))
+	// User-defined configurations follow the built-in ones, sorted by name.
+	var definedConfigs []string
+	for config := range opts.Configurations {
+		if !contains(supportedConfigs, config) {
+			definedConfigs = append(definedConfigs, config)
+		}
 	}
+	sort.Strings(definedConfigs)
+	allConfigs := append(supportedConfigs, definedConfigs...)
 
-	// ReleaseASAN
-	if contains(supportedConfigs, "ReleaseASAN") {
-		sb.WriteString(fmt.Sprintf("set(CMAKE_CXX_FLAGS_RELEASEASAN_INIT %q)\n", strings.Join(relASANFlags, " ")))
-		sb.WriteString(fmt.Sprintf("set(CMAKE_C_FLAGS_RELEASEASAN_INIT %q)\n", strings.Join(relASANFlags, " ")))
+	if len(allConfigs) > 0 {
+		sb.WriteString(fmt.Sprintf("set(CMAKE_CONFIGURATION_TYPES %q CACHE STRING \"\" FORCE)\n", strings.Join(allConfigs, ";")))
 	}
 
-	// ReleaseTSAN
-	if contains(supportedConfigs, "ReleaseTSAN") {
-		sb.WriteString(fmt.Sprintf("set(CMAKE_CXX_FLAGS_RELEASETSAN_INIT %q)\n", strings.Join(relTSANFlags, " ")))
-		sb.WriteString(fmt.Sprintf("set(CMAKE_C_FLAGS_RELEASETSAN_INIT %q)\n", strings.Join(relTSANFlags, " ")))
+	for _, config := range allConfigs {
+		cfg := configs[config]
+		suffix := cmakeConfigVarName(config)
+		sb.WriteString(fmt.Sprintf("set(CMAKE_CXX_FLAGS_%s_INIT %q)\n", suffix, strings.Join(cfg.CXXFlags, " ")))
+		sb.WriteString(fmt.Sprintf("set(CMAKE_C_FLAGS_%s_INIT %q)\n", suffix, strings.Join(cfg.CFlags, " ")))
+		linkerFlags := strings.Join(cfg.LinkerFlags, " ")
+		sb.WriteString(fmt.Sprintf("set(CMAKE_EXE_LINKER_FLAGS_%s_INIT %q)\n", suffix, linkerFlags))
+		sb.WriteString(fmt.Sprintf("set(CMAKE_SHARED_LINKER_FLAGS_%s_INIT %q)\n", suffix, linkerFlags))
+		sb.WriteString(fmt.Sprintf("set(CMAKE_MODULE_LINKER_FLAGS_%s_INIT %q)\n", suffix, linkerFlags))
 	}
 
-	sb.WriteString("set(CMAKE_FIND_ROOT_PATH_MODE_PACKAGE NEVER)\n")
-
 	err = os.MkdirAll(filepath.Dir(opts.OutputFile), 0755)
 	if err != nil {
 		return fmt.Errorf("failed to create directory for toolchain file: %w", err)
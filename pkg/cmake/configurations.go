@@ -0,0 +1,110 @@
+package cmake
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ConfigurationDefinition describes the flags of a build configuration. The
+// flags are added to those of the configuration it inherits from, or, for a
+// configuration named like a built-in one, to the built-in flags.
+type ConfigurationDefinition struct {
+	Inherits    string   `yaml:"inherits,omitempty"`
+	CFlags      []string `yaml:"c_flags,omitempty"`
+	CXXFlags    []string `yaml:"cxx_flags,omitempty"`
+	LinkerFlags []string `yaml:"linker_flags,omitempty"`
+}
+
+// ResolveConfigurations returns the flags of every built-in and defined
+// configuration with inheritance applied.
+func ResolveConfigurations(builtin map[string]ConfigurationDefinition, defs map[string]*ConfigurationDefinition) (map[string]ConfigurationDefinition, error) {
+	resolved := make(map[string]ConfigurationDefinition)
+	for name, cfg := range builtin {
+		resolved[name] = cfg
+	}
+
+	visiting := make(map[string]bool)
+	done := make(map[string]bool)
+
+	var resolve func(name string, path []string) (ConfigurationDefinition, error)
+	resolve = func(name string, path []string) (ConfigurationDefinition, error) {
+		def, ok := defs[name]
+		if !ok {
+			cfg, ok := builtin[name]
+			if !ok {
+				return ConfigurationDefinition{}, fmt.Errorf("configuration %s not found", name)
+			}
+			return cfg, nil
+		}
+		if done[name] {
+			return resolved[name], nil
+		}
+		if visiting[name] {
+			return ConfigurationDefinition{}, fmt.Errorf("configuration inheritance cycle detected: %s", strings.Join(append(path, name), " -> "))
+		}
+		visiting[name] = true
+
+		if def == nil {
+			def = &ConfigurationDefinition{}
+		}
+
+		var base ConfigurationDefinition
+		switch {
+		case def.Inherits == name:
+			cfg, ok := builtin[name]
+			if !ok {
+				return ConfigurationDefinition{}, fmt.Errorf("configuration %s inherits from itself", name)
+			}
+			base = cfg
+		case def.Inherits != "":
+			cfg, err := resolve(def.Inherits, append(path, name))
+			if err != nil {
+				return ConfigurationDefinition{}, fmt.Errorf("configuration %s: %w", name, err)
+			}
+			base = cfg
+		default:
+			base = builtin[name]
+		}
+
+		cfg := ConfigurationDefinition{
+			Inherits:    def.Inherits,
+			CFlags:      concatFlags(base.CFlags, def.CFlags),
+			CXXFlags:    concatFlags(base.CXXFlags, def.CXXFlags),
+			LinkerFlags: concatFlags(base.LinkerFlags, def.LinkerFlags),
+		}
+
+		visiting[name] = false
+		done[name] = true
+		resolved[name] = cfg
+		return cfg, nil
+	}
+
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		_, err := resolve(name, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resolved, nil
+}
+
+// cmakeConfigVarName returns the suffix CMake uses for per-configuration
+// variables, e.g. "RELWITHDEBINFO" for "RelWithDebInfo".
+func cmakeConfigVarName(config string) string {
+	return strings.ToUpper(config)
+}
+
+func concatFlags(a []string, b []string) []string {
+	flags := make([]string, 0, len(a)+len(b))
+	flags = append(flags, a...)
+	flags = append(flags, b...)
+	return flags
+}
@@ -0,0 +1,111 @@
+package cmake
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/system"
+)
+
+func TestResolveConfigurations(t *testing.T) {
+	builtin := map[string]ConfigurationDefinition{
+		"Release": {CFlags: []string{"-O3"}, CXXFlags: []string{"-O3"}},
+	}
+	defs := map[string]*ConfigurationDefinition{
+		"Hardened": {Inherits: "Release", CXXFlags: []string{"-D_FORTIFY_SOURCE=2"}, LinkerFlags: []string{"-Wl,-z,relro"}},
+		"Paranoid": {Inherits: "Hardened", CFlags: []string{"-fstack-protector-strong"}},
+		"Release":  {CFlags: []string{"-g"}},
+		"Plain":    nil,
+	}
+
+	configs, err := ResolveConfigurations(builtin, defs)
+	if err != nil {
+		t.Fatalf("ResolveConfigurations() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want ConfigurationDefinition
+	}{
+		{"Release", ConfigurationDefinition{CFlags: []string{"-O3", "-g"}, CXXFlags: []string{"-O3"}, LinkerFlags: []string{}}},
+		{"Hardened", ConfigurationDefinition{Inherits: "Release", CFlags: []string{"-O3", "-g"}, CXXFlags: []string{"-O3", "-D_FORTIFY_SOURCE=2"}, LinkerFlags: []string{"-Wl,-z,relro"}}},
+		{"Paranoid", ConfigurationDefinition{Inherits: "Hardened", CFlags: []string{"-O3", "-g", "-fstack-protector-strong"}, CXXFlags: []string{"-O3", "-D_FORTIFY_SOURCE=2"}, LinkerFlags: []string{"-Wl,-z,relro"}}},
+		{"Plain", ConfigurationDefinition{CFlags: []string{}, CXXFlags: []string{}, LinkerFlags: []string{}}},
+	}
+
+	for _, tt := range tests {
+		if got := configs[tt.name]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("configuration %s = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveConfigurationsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		defs map[string]*ConfigurationDefinition
+	}{
+		{"cycle", map[string]*ConfigurationDefinition{"A": {Inherits: "B"}, "B": {Inherits: "A"}}},
+		{"unknown base", map[string]*ConfigurationDefinition{"A": {Inherits: "Missing"}}},
+		{"self", map[string]*ConfigurationDefinition{"A": {Inherits: "A"}}},
+	}
+
+	for _, tt := range tests {
+		_, err := ResolveConfigurations(nil, tt.defs)
+		if err == nil {
+			t.Errorf("%s: ResolveConfigurations() should fail", tt.name)
+		}
+	}
+}
+
+func TestGenerateToolchainFileConfigurations(t *testing.T) {
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "toolchain.cmake")
+
+	err := GenerateToolchainFile(context.Background(), GenerateToolchainFileOptions{
+		CompilerType:    CompilerTypeGCC,
+		CCompiler:       "gcc",
+		CXXCompiler:     "g++",
+		SystemPlatform:  system.PlatformLinux,
+		SystemProcessor: system.ProcessorX64,
+		WorkspaceDir:    dir,
+		OutputFile:      outputFile,
+
+		ExtraLinkerFlags:       []string{"-Wl,--as-needed"},
+		ExtraSharedLinkerFlags: []string{"-Wl,--no-undefined"},
+		ExtraExeLinkerFlags:    []string{"-pie"},
+		Configurations: map[string]*ConfigurationDefinition{
+			"Hardened": {Inherits: "Release", CXXFlags: []string{"-fstack-protector-strong"}, LinkerFlags: []string{"-Wl,-z,now"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("GenerateToolchainFile() error = %v", err)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`set(CMAKE_CXX_FLAGS_HARDENED_INIT "-O3 -DNDEBUG -fstack-protector-strong")`,
+		`set(CMAKE_C_FLAGS_HARDENED_INIT "-O3 -DNDEBUG")`,
+		`set(CMAKE_EXE_LINKER_FLAGS_HARDENED_INIT "-Wl,-z,now")`,
+		`set(CMAKE_SHARED_LINKER_FLAGS_HARDENED_INIT "-Wl,-z,now")`,
+		`ReleaseTSAN;Hardened" CACHE STRING`,
+		`set(CMAKE_EXE_LINKER_FLAGS_DEBUGASAN_INIT "-fsanitize=address -fsanitize=undefined")`,
+		`set(CMAKE_SHARED_LINKER_FLAGS_DEBUGCOVERAGE_INIT "--coverage")`,
+		`set(CMAKE_EXE_LINKER_FLAGS_RELEASE_INIT "")`,
+		`set(CMAKE_EXE_LINKER_FLAGS_INIT "-Wl,--as-needed -pie")`,
+		`set(CMAKE_SHARED_LINKER_FLAGS_INIT "-Wl,--as-needed -Wl,--no-undefined")`,
+		`set(CMAKE_MODULE_LINKER_FLAGS_INIT "-Wl,--as-needed -Wl,--no-undefined")`,
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("toolchain file does not contain %q:\n%s", want, content)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package cmake
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// FindRootPathModes sets CMAKE_FIND_ROOT_PATH_MODE_<CATEGORY> for each
+// category of find command: NEVER, ONLY or BOTH.
+type FindRootPathModes struct {
+	Program string `yaml:"program,omitempty"`
+	Library string `yaml:"library,omitempty"`
+	Include string `yaml:"include,omitempty"`
+	Package string `yaml:"package,omitempty"`
+}
+
+// crossSettings returns the toolchain file lines for the sysroot, target
+// triple and find root settings. Relative paths are resolved against the
+// workspace directory.
+//
+// When a sysroot or find root path is set, programs are found on the host and
+// libraries and headers only in the roots, as is usual for cross compiling.
+// Packages are never searched in the roots by default, so that the staged
+// dependencies on CMAKE_PREFIX_PATH are found. The CMAKE_PREFIX_PATH entries
+// are added to the roots, so that find_library and find_path also search the
+// staged dependencies in ONLY mode.
+func crossSettings(opts GenerateToolchainFileOptions, workspaceDir string) (string, error) {
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(workspaceDir, path)
+	}
+
+	var sb strings.Builder
+
+	if opts.Sysroot != "" {
+		sb.WriteString(fmt.Sprintf("set(CMAKE_SYSROOT %q)\n", resolve(opts.Sysroot)))
+	}
+
+	// CMake passes --target= to clang for the compiler target.
+	if opts.TargetTriple != "" {
+		sb.WriteString(fmt.Sprintf("set(CMAKE_C_COMPILER_TARGET %q)\n", opts.TargetTriple))
+		sb.WriteString(fmt.Sprintf("set(CMAKE_CXX_COMPILER_TARGET %q)\n", opts.TargetTriple))
+	}
+
+	if len(opts.FindRootPath) > 0 {
+		var roots []string
+		for _, root := range opts.FindRootPath {
+			roots = append(roots, resolve(root))
+		}
+		sb.WriteString(fmt.Sprintf("set(CMAKE_FIND_ROOT_PATH %q)\n", strings.Join(roots, ";")))
+	}
+
+	modes := FindRootPathModes{Package: "NEVER"}
+	if opts.Sysroot != "" || len(opts.FindRootPath) > 0 {
+		// cbuild passes the staging directories on the command line, so they
+		// are known by the time the toolchain file is read.
+		sb.WriteString("if(CMAKE_PREFIX_PATH)\n")
+		sb.WriteString("  list(APPEND CMAKE_FIND_ROOT_PATH ${CMAKE_PREFIX_PATH})\n")
+		sb.WriteString("  list(REMOVE_DUPLICATES CMAKE_FIND_ROOT_PATH)\n")
+		sb.WriteString("endif()\n")
+
+		modes.Program = "NEVER"
+		modes.Library = "ONLY"
+		modes.Include = "ONLY"
+	}
+	if opts.FindRootPathModes != nil {
+		if opts.FindRootPathModes.Program != "" {
+			modes.Program = opts.FindRootPathModes.Program
+		}
+		if opts.FindRootPathModes.Library != "" {
+			modes.Library = opts.FindRootPathModes.Library
+		}
+		if opts.FindRootPathModes.Include != "" {
+			modes.Include = opts.FindRootPathModes.Include
+		}
+		if opts.FindRootPathModes.Package != "" {
+			modes.Package = opts.FindRootPathModes.Package
+		}
+	}
+
+	for _, mode := range []struct {
+		category string
+		value    string
+	}{
+		{"PROGRAM", modes.Program},
+		{"LIBRARY", modes.Library},
+		{"INCLUDE", modes.Include},
+		{"PACKAGE", modes.Package},
+	} {
+		if mode.value == "" {
+			continue
+		}
+		value := strings.ToUpper(mode.value)
+		if value != "NEVER" && value != "ONLY" && value != "BOTH" {
+			return "", fmt.Errorf("invalid find root path mode for %s: %s, must be NEVER, ONLY or BOTH", strings.ToLower(mode.category), mode.value)
+		}
+		sb.WriteString(fmt.Sprintf("set(CMAKE_FIND_ROOT_PATH_MODE_%s %s)\n", mode.category, value))
+	}
+
+	return sb.String(), nil
+}
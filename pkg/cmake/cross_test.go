@@ -0,0 +1,103 @@
+package cmake
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCrossSettings(t *testing.T) {
+	workspaceDir := "/work"
+
+	tests := []struct {
+		name    string
+		opts    GenerateToolchainFileOptions
+		want    []string
+		notWant []string
+		wantErr bool
+	}{
+		{
+			name:    "native",
+			opts:    GenerateToolchainFileOptions{},
+			want:    []string{"set(CMAKE_FIND_ROOT_PATH_MODE_PACKAGE NEVER)"},
+			notWant: []string{"CMAKE_SYSROOT", "CMAKE_FIND_ROOT_PATH_MODE_LIBRARY", "list(APPEND CMAKE_FIND_ROOT_PATH"},
+		},
+		{
+			name: "sysroot and triple",
+			opts: GenerateToolchainFileOptions{
+				Sysroot:      "sysroots/aarch64",
+				TargetTriple: "aarch64-linux-gnu",
+				FindRootPath: []string{"/opt/aarch64"},
+			},
+			want: []string{
+				`set(CMAKE_SYSROOT "` + filepath.Join(workspaceDir, "sysroots/aarch64") + `")`,
+				`set(CMAKE_C_COMPILER_TARGET "aarch64-linux-gnu")`,
+				`set(CMAKE_CXX_COMPILER_TARGET "aarch64-linux-gnu")`,
+				`set(CMAKE_FIND_ROOT_PATH "/opt/aarch64")`,
+				"list(APPEND CMAKE_FIND_ROOT_PATH ${CMAKE_PREFIX_PATH})",
+				"set(CMAKE_FIND_ROOT_PATH_MODE_PROGRAM NEVER)",
+				"set(CMAKE_FIND_ROOT_PATH_MODE_LIBRARY ONLY)",
+				"set(CMAKE_FIND_ROOT_PATH_MODE_INCLUDE ONLY)",
+				"set(CMAKE_FIND_ROOT_PATH_MODE_PACKAGE NEVER)",
+			},
+		},
+		{
+			name: "sysroot only",
+			opts: GenerateToolchainFileOptions{Sysroot: "/sysroot"},
+			want: []string{
+				"list(APPEND CMAKE_FIND_ROOT_PATH ${CMAKE_PREFIX_PATH})",
+				"set(CMAKE_FIND_ROOT_PATH_MODE_LIBRARY ONLY)",
+			},
+			notWant: []string{`set(CMAKE_FIND_ROOT_PATH "`},
+		},
+		{
+			name: "mode overrides",
+			opts: GenerateToolchainFileOptions{
+				Sysroot:           "/sysroot",
+				FindRootPathModes: &FindRootPathModes{Library: "both", Package: "ONLY"},
+			},
+			want: []string{
+				"set(CMAKE_FIND_ROOT_PATH_MODE_LIBRARY BOTH)",
+				"set(CMAKE_FIND_ROOT_PATH_MODE_PACKAGE ONLY)",
+			},
+		},
+		{
+			name:    "invalid mode",
+			opts:    GenerateToolchainFileOptions{FindRootPathModes: &FindRootPathModes{Include: "sometimes"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := crossSettings(tt.opts, workspaceDir)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: crossSettings() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: crossSettings() does not contain %q:\n%s", tt.name, want, got)
+			}
+		}
+		for _, notWant := range tt.notWant {
+			if strings.Contains(got, notWant) {
+				t.Errorf("%s: crossSettings() contains %q:\n%s", tt.name, notWant, got)
+			}
+		}
+	}
+}
+
+func TestCrossSettingsStagingRoots(t *testing.T) {
+	got, err := crossSettings(GenerateToolchainFileOptions{Sysroot: "/sysroot", FindRootPath: []string{"/opt/aarch64"}}, "/work")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The staging directories must be appended after the roots are set, or
+	// the set would drop them.
+	set := strings.Index(got, `set(CMAKE_FIND_ROOT_PATH "/opt/aarch64")`)
+	appendRoots := strings.Index(got, "list(APPEND CMAKE_FIND_ROOT_PATH ${CMAKE_PREFIX_PATH})")
+	if set < 0 || appendRoots < 0 || appendRoots < set {
+		t.Errorf("crossSettings() does not append CMAKE_PREFIX_PATH after setting CMAKE_FIND_ROOT_PATH:\n%s", got)
+	}
+}
@@ -0,0 +1,147 @@
+package cmake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fileAPIClient is the client name cbuild uses for CMake File API queries.
+const fileAPIClient = "client-cbuild"
+
+// Executable is an executable target reported by the CMake File API.
+type Executable struct {
+	Name string
+	// Path is the absolute path of the built executable.
+	Path string
+}
+
+// WriteFileAPIQuery requests the codemodel from the CMake File API, so that
+// the next configure of buildDir writes a reply describing its targets.
+func WriteFileAPIQuery(buildDir string) error {
+	queryDir := filepath.Join(buildDir, ".cmake", "api", "v1", "query", fileAPIClient)
+	err := os.MkdirAll(queryDir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create file API query directory: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(queryDir, "codemodel-v2"), nil, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write file API query: %w", err)
+	}
+	return nil
+}
+
+type fileAPIIndex struct {
+	Reply map[string]json.RawMessage `json:"reply"`
+}
+
+type fileAPIObject struct {
+	Kind     string `json:"kind"`
+	JSONFile string `json:"jsonFile"`
+}
+
+type fileAPICodemodel struct {
+	Configurations []struct {
+		Name    string `json:"name"`
+		Targets []struct {
+			Name     string `json:"name"`
+			JSONFile string `json:"jsonFile"`
+		} `json:"targets"`
+	} `json:"configurations"`
+}
+
+type fileAPITarget struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Artifacts []struct {
+		Path string `json:"path"`
+	} `json:"artifacts"`
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadExecutables returns the executable targets of a configured build tree
+// for the given configuration, using the reply to WriteFileAPIQuery.
+func ReadExecutables(buildDir string, config string) ([]Executable, error) {
+	replyDir := filepath.Join(buildDir, ".cmake", "api", "v1", "reply")
+
+	indexFiles, err := filepath.Glob(filepath.Join(replyDir, "index-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(indexFiles) == 0 {
+		return nil, errors.New("no CMake file API reply found, reconfigure the target")
+	}
+	// Index files are named by timestamp, the last one is the most recent.
+	sort.Strings(indexFiles)
+
+	var index fileAPIIndex
+	err = readJSON(indexFiles[len(indexFiles)-1], &index)
+	if err != nil {
+		return nil, err
+	}
+
+	clientReply, ok := index.Reply[fileAPIClient]
+	if !ok {
+		return nil, errors.New("CMake file API reply has no cbuild query results")
+	}
+	var replies map[string]fileAPIObject
+	err = json.Unmarshal(clientReply, &replies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file API reply: %w", err)
+	}
+	codemodelRef, ok := replies["codemodel-v2"]
+	if !ok || codemodelRef.JSONFile == "" {
+		return nil, errors.New("CMake file API reply has no codemodel")
+	}
+
+	var codemodel fileAPICodemodel
+	err = readJSON(filepath.Join(replyDir, codemodelRef.JSONFile), &codemodel)
+	if err != nil {
+		return nil, err
+	}
+
+	var executables []Executable
+	for _, cfg := range codemodel.Configurations {
+		// Single-config generators report the configuration set by CMAKE_BUILD_TYPE.
+		if len(codemodel.Configurations) > 1 && !strings.EqualFold(cfg.Name, config) {
+			continue
+		}
+		for _, t := range cfg.Targets {
+			var target fileAPITarget
+			err = readJSON(filepath.Join(replyDir, t.JSONFile), &target)
+			if err != nil {
+				return nil, err
+			}
+			if target.Type != "EXECUTABLE" || len(target.Artifacts) == 0 {
+				continue
+			}
+
+			path := filepath.FromSlash(target.Artifacts[0].Path)
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(buildDir, path)
+			}
+			executables = append(executables, Executable{Name: target.Name, Path: path})
+		}
+	}
+
+	sort.Slice(executables, func(i, j int) bool {
+		return executables[i].Name < executables[j].Name
+	})
+	return executables, nil
+}
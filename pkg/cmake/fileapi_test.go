@@ -0,0 +1,71 @@
+package cmake
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeReplyFile(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+	err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadExecutables(t *testing.T) {
+	buildDir := t.TempDir()
+
+	err := WriteFileAPIQuery(buildDir)
+	if err != nil {
+		t.Fatalf("WriteFileAPIQuery() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(buildDir, ".cmake", "api", "v1", "query", "client-cbuild", "codemodel-v2")); err != nil {
+		t.Fatalf("query file not written: %v", err)
+	}
+
+	_, err = ReadExecutables(buildDir, "Debug")
+	if err == nil {
+		t.Fatal("ReadExecutables() without a reply should fail")
+	}
+
+	replyDir := filepath.Join(buildDir, ".cmake", "api", "v1", "reply")
+	if err := os.MkdirAll(replyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeReplyFile(t, replyDir, "index-2024-01-01T00-00-00-0000.json",
+		`{"reply": {"client-cbuild": {"codemodel-v2": {"kind": "codemodel", "jsonFile": "codemodel-v2-1.json"}}}}`)
+	writeReplyFile(t, replyDir, "codemodel-v2-1.json", `{"configurations": [
+		{"name": "Debug", "targets": [
+			{"name": "tool", "jsonFile": "target-tool.json"},
+			{"name": "lib", "jsonFile": "target-lib.json"},
+			{"name": "app", "jsonFile": "target-app.json"}
+		]},
+		{"name": "Release", "targets": [
+			{"name": "other", "jsonFile": "target-other.json"}
+		]}
+	]}`)
+	writeReplyFile(t, replyDir, "target-tool.json", `{"name": "tool", "type": "EXECUTABLE", "artifacts": [{"path": "tools/tool"}]}`)
+	writeReplyFile(t, replyDir, "target-lib.json", `{"name": "lib", "type": "SHARED_LIBRARY", "artifacts": [{"path": "liblib.so"}]}`)
+	writeReplyFile(t, replyDir, "target-app.json", `{"name": "app", "type": "EXECUTABLE", "artifacts": [{"path": "app"}]}`)
+	writeReplyFile(t, replyDir, "target-other.json", `{"name": "other", "type": "EXECUTABLE", "artifacts": [{"path": "other"}]}`)
+
+	got, err := ReadExecutables(buildDir, "debug")
+	if err != nil {
+		t.Fatalf("ReadExecutables() error = %v", err)
+	}
+
+	want := []Executable{
+		{Name: "app", Path: filepath.Join(buildDir, "app")},
+		{Name: "tool", Path: filepath.Join(buildDir, "tools", "tool")},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadExecutables() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ReadExecutables()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
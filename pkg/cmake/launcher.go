@@ -0,0 +1,35 @@
+package cmake
+
+import (
+	"fmt"
+	"strings"
+)
+
+// compilerLauncherLanguages are the languages a compiler launcher is set for.
+var compilerLauncherLanguages = []string{"C", "CXX"}
+
+// compilerLauncherSettings returns the toolchain file lines that run the
+// compilers through a launcher such as ccache.
+func compilerLauncherSettings(launcher string) string {
+	if launcher == "" {
+		return ""
+	}
+	var sb strings.Builder
+	for _, lang := range compilerLauncherLanguages {
+		sb.WriteString(fmt.Sprintf("set(CMAKE_%s_COMPILER_LAUNCHER %q)\n", lang, launcher))
+	}
+	return sb.String()
+}
+
+// CompilerLauncherCacheArgs returns the cmake configure arguments that run the
+// compilers through a launcher, for toolchain files that are not generated.
+func CompilerLauncherCacheArgs(launcher string) []string {
+	if launcher == "" {
+		return nil
+	}
+	var args []string
+	for _, lang := range compilerLauncherLanguages {
+		args = append(args, fmt.Sprintf("-DCMAKE_%s_COMPILER_LAUNCHER=%s", lang, launcher))
+	}
+	return args
+}
@@ -0,0 +1,60 @@
+package cmake
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/system"
+)
+
+func TestGenerateToolchainFileCompilerLauncher(t *testing.T) {
+	dir := t.TempDir()
+
+	opts := GenerateToolchainFileOptions{
+		CompilerType:     CompilerTypeGCC,
+		CCompiler:        "gcc",
+		CXXCompiler:      "g++",
+		CompilerLauncher: "ccache",
+		SystemPlatform:   system.PlatformLinux,
+		SystemProcessor:  system.ProcessorX64,
+		WorkspaceDir:     dir,
+		OutputFile:       filepath.Join(dir, "toolchain.cmake"),
+	}
+
+	err := GenerateToolchainFile(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("GenerateToolchainFile() error = %v", err)
+	}
+
+	content, err := os.ReadFile(opts.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`set(CMAKE_C_COMPILER_LAUNCHER "ccache")`,
+		`set(CMAKE_CXX_COMPILER_LAUNCHER "ccache")`,
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("toolchain file does not contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestCompilerLauncherCacheArgs(t *testing.T) {
+	if args := CompilerLauncherCacheArgs(""); args != nil {
+		t.Errorf("CompilerLauncherCacheArgs(\"\") = %v, want nil", args)
+	}
+
+	want := []string{
+		"-DCMAKE_C_COMPILER_LAUNCHER=sccache",
+		"-DCMAKE_CXX_COMPILER_LAUNCHER=sccache",
+	}
+	if got := CompilerLauncherCacheArgs("sccache"); !reflect.DeepEqual(got, want) {
+		t.Errorf("CompilerLauncherCacheArgs(\"sccache\") = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package cmake
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func (l LinkerType) String() string {
+	switch l {
+	case LinkerTypeGNULD:
+		return "bfd"
+	case LinkerTypeLLD:
+		return "lld"
+	case LinkerTypeGold:
+		return "gold"
+	case LinkerTypeMold:
+		return "mold"
+	}
+	return "default"
+}
+
+func (l LinkerType) MarshalYAML() (interface{}, error) {
+	return l.String(), nil
+}
+
+func (l *LinkerType) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+	linkerType, err := ParseLinkerType(s)
+	if err != nil {
+		return errors.New("LinkerType.UnmarshalYAML: " + err.Error())
+	}
+	*l = linkerType
+	return nil
+}
+
+// ParseLinkerType parses a linker name: bfd, lld, gold, mold or default.
+func ParseLinkerType(s string) (LinkerType, error) {
+	switch strings.ToLower(s) {
+	case "bfd", "ld":
+		return LinkerTypeGNULD, nil
+	case "lld":
+		return LinkerTypeLLD, nil
+	case "gold":
+		return LinkerTypeGold, nil
+	case "mold":
+		return LinkerTypeMold, nil
+	case "", "default":
+		return LinkerTypeUnknown, nil
+	}
+	return LinkerTypeUnknown, errors.New("unrecognized linker type: " + s)
+}
+
+// LinkerBinary returns the name of the linker executable the compiler driver
+// looks for, e.g. "ld.lld", or "" for the default linker.
+func (l LinkerType) LinkerBinary() string {
+	if l == LinkerTypeUnknown {
+		return ""
+	}
+	return "ld." + l.String()
+}
+
+// linkerSelection returns the toolchain file lines that make the compiler
+// driver link with the given linker. CMake 3.29 and newer select it with
+// CMAKE_LINKER_TYPE, older versions get -fuse-ld in the linker flags.
+func linkerSelection(compilerType CompilerType, linkerType LinkerType) (string, error) {
+	if linkerType == LinkerTypeUnknown {
+		return "", nil
+	}
+	if compilerType != CompilerTypeGCC && compilerType != CompilerTypeClang {
+		return "", fmt.Errorf("linker type %s is not supported for %s", linkerType, compilerType)
+	}
+
+	fuseLd := "-fuse-ld=" + linkerType.String()
+
+	var sb strings.Builder
+	sb.WriteString("if(CMAKE_VERSION VERSION_GREATER_EQUAL 3.29)\n")
+	sb.WriteString(fmt.Sprintf("  set(CMAKE_LINKER_TYPE %s)\n", strings.ToUpper(linkerType.String())))
+	sb.WriteString("else()\n")
+	for _, kind := range []string{"EXE", "SHARED", "MODULE"} {
+		sb.WriteString(fmt.Sprintf("  string(APPEND CMAKE_%s_LINKER_FLAGS_INIT \" %s\")\n", kind, fuseLd))
+	}
+	sb.WriteString("endif()\n")
+	return sb.String(), nil
+}
@@ -0,0 +1,77 @@
+package cmake
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/rpnx/cbuild-go/pkg/system"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLinkerTypeYAML(t *testing.T) {
+	for _, want := range []LinkerType{LinkerTypeUnknown, LinkerTypeGNULD, LinkerTypeLLD, LinkerTypeGold, LinkerTypeMold} {
+		data, err := yaml.Marshal(want)
+		if err != nil {
+			t.Fatalf("yaml.Marshal(%v) error = %v", want, err)
+		}
+		var got LinkerType
+		err = yaml.Unmarshal(data, &got)
+		if err != nil {
+			t.Fatalf("yaml.Unmarshal(%q) error = %v", data, err)
+		}
+		if got != want {
+			t.Errorf("round trip of %v = %v", want, got)
+		}
+	}
+
+	var l LinkerType
+	if err := yaml.Unmarshal([]byte("ld64"), &l); err == nil {
+		t.Error("yaml.Unmarshal of an unknown linker type should fail")
+	}
+}
+
+func TestGenerateToolchainFileLinkerType(t *testing.T) {
+	dir := t.TempDir()
+
+	opts := GenerateToolchainFileOptions{
+		CompilerType:    CompilerTypeClang,
+		CCompiler:       "clang",
+		CXXCompiler:     "clang++",
+		LinkerType:      LinkerTypeLLD,
+		SystemPlatform:  system.PlatformLinux,
+		SystemProcessor: system.ProcessorX64,
+		WorkspaceDir:    dir,
+		OutputFile:      filepath.Join(dir, "toolchain.cmake"),
+	}
+
+	err := GenerateToolchainFile(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("GenerateToolchainFile() error = %v", err)
+	}
+
+	content, err := os.ReadFile(opts.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"set(CMAKE_LINKER_TYPE LLD)",
+		`string(APPEND CMAKE_EXE_LINKER_FLAGS_INIT " -fuse-ld=lld")`,
+		`string(APPEND CMAKE_SHARED_LINKER_FLAGS_INIT " -fuse-ld=lld")`,
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("toolchain file does not contain %q:\n%s", want, content)
+		}
+	}
+
+	opts.CompilerType = CompilerTypeMSVC
+	opts.CCompiler = "cl"
+	opts.CXXCompiler = "cl"
+	err = GenerateToolchainFile(context.Background(), opts)
+	if err == nil {
+		t.Error("GenerateToolchainFile() with a linker type for MSVC should fail")
+	}
+}
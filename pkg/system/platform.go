@@ -44,19 +44,27 @@ func (p *Platform) UnmarshalYAML(value *yaml.Node) error {
 	if err := value.Decode(&s); err != nil {
 		return err
 	}
+	platform, err := ParsePlatform(s)
+	if err != nil {
+		return errors.New("Platform.UnmarshalYAML: " + err.Error())
+	}
+	*p = platform
+	return nil
+}
+
+// ParsePlatform parses a platform name such as "linux" or "macos".
+func ParsePlatform(s string) (Platform, error) {
 	switch strings.ToLower(s) {
 	case "windows":
-		*p = PlatformWindows
+		return PlatformWindows, nil
 	case "mac", "macos", "darwin":
-		*p = PlatformMac
+		return PlatformMac, nil
 	case "linux":
-		*p = PlatformLinux
+		return PlatformLinux, nil
 	case "freebsd":
-		*p = PlatformFreeBSD
+		return PlatformFreeBSD, nil
 	case "unknown":
-		*p = PlatformUnknown
-	default:
-		return errors.New("Platform.UnmarshalYAML: unrecognized platform: " + s)
+		return PlatformUnknown, nil
 	}
-	return nil
+	return PlatformUnknown, errors.New("unrecognized platform: " + s)
 }
@@ -1,6 +1,7 @@
 package system
 
 import (
+	"errors"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -49,21 +50,27 @@ func (p *Processor) UnmarshalYAML(value *yaml.Node) error {
 	if err := value.Decode(&s); err != nil {
 		return err
 	}
+	// Unrecognized processors, such as a toolchain's target_arch of a
+	// processor cbuild does not know, load as unknown.
+	*p, _ = ParseProcessor(s)
+	return nil
+}
+
+// ParseProcessor parses a processor name such as "x64" or "aarch64".
+func ParseProcessor(s string) (Processor, error) {
 	switch strings.ToLower(s) {
 	case "x86", "i386", "i686":
-		*p = ProcessorX86
+		return ProcessorX86, nil
 	case "x64", "x86_64", "amd64":
-		*p = ProcessorX64
+		return ProcessorX64, nil
 	case "arm", "arm32", "armv7l":
-		*p = ProcessorArm32
+		return ProcessorArm32, nil
 	case "arm64", "aarch64":
-		*p = ProcessorArm64
+		return ProcessorArm64, nil
 	case "riscv32":
-		*p = ProcessorRISCV32
+		return ProcessorRISCV32, nil
 	case "riscv64":
-		*p = ProcessorRISCV64
-	default:
-		*p = ProcessorUnknown
+		return ProcessorRISCV64, nil
 	}
-	return nil
+	return ProcessorUnknown, errors.New("unrecognized processor: " + s)
 }
@@ -100,3 +100,53 @@ func TestProcessorYAML(t *testing.T) {
 		}
 	}
 }
+
+func TestPlatformYAMLInvalid(t *testing.T) {
+	for _, s := range []string{"plan9", "linuxx"} {
+		var p Platform
+		if err := yaml.Unmarshal([]byte(s), &p); err == nil {
+			t.Errorf("yaml.Unmarshal(%q) into Platform = %v, want an error", s, p)
+		}
+	}
+}
+
+func TestProcessorYAMLUnrecognized(t *testing.T) {
+	for _, s := range []string{"s390x", "ppc64le", "armv9"} {
+		p := ProcessorX64
+		if err := yaml.Unmarshal([]byte(s), &p); err != nil || p != ProcessorUnknown {
+			t.Errorf("yaml.Unmarshal(%q) into Processor = %v, %v, want unknown", s, p, err)
+		}
+	}
+}
+
+func TestParseTriple(t *testing.T) {
+	tests := []struct {
+		triple    string
+		platform  Platform
+		processor Processor
+		wantErr   bool
+	}{
+		{"x86_64-linux-gnu", PlatformLinux, ProcessorX64, false},
+		{"x86_64-pc-linux-gnu", PlatformLinux, ProcessorX64, false},
+		{"aarch64-linux-gnu\n", PlatformLinux, ProcessorArm64, false},
+		{"arm-linux-gnueabihf", PlatformLinux, ProcessorArm32, false},
+		{"riscv64-linux-gnu", PlatformLinux, ProcessorRISCV64, false},
+		{"arm64-apple-darwin23.4.0", PlatformMac, ProcessorArm64, false},
+		{"x86_64-w64-mingw32", PlatformWindows, ProcessorX64, false},
+		{"i686-w64-mingw32", PlatformWindows, ProcessorX86, false},
+		{"x86_64-unknown-freebsd14.0", PlatformFreeBSD, ProcessorX64, false},
+		{"arm-none-eabi", PlatformUnknown, ProcessorUnknown, true},
+		{"gcc", PlatformUnknown, ProcessorUnknown, true},
+	}
+
+	for _, tt := range tests {
+		platform, processor, err := ParseTriple(tt.triple)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTriple(%q) error = %v, wantErr %v", tt.triple, err, tt.wantErr)
+			continue
+		}
+		if platform != tt.platform || processor != tt.processor {
+			t.Errorf("ParseTriple(%q) = %v, %v, want %v, %v", tt.triple, platform, processor, tt.platform, tt.processor)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package system
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseTriple returns the platform and processor of a target triple such as
+// "aarch64-linux-gnu" or "x86_64-w64-mingw32", as printed by `cc -dumpmachine`.
+func ParseTriple(triple string) (Platform, Processor, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(triple)), "-")
+	if len(parts) < 2 {
+		return PlatformUnknown, ProcessorUnknown, fmt.Errorf("invalid target triple: %q", triple)
+	}
+
+	var processor Processor
+	arch := parts[0]
+	switch {
+	case arch == "x86_64" || arch == "amd64":
+		processor = ProcessorX64
+	case arch == "i386" || arch == "i486" || arch == "i586" || arch == "i686":
+		processor = ProcessorX86
+	case arch == "aarch64" || arch == "arm64":
+		processor = ProcessorArm64
+	case strings.HasPrefix(arch, "arm"):
+		processor = ProcessorArm32
+	case arch == "riscv64":
+		processor = ProcessorRISCV64
+	case arch == "riscv32":
+		processor = ProcessorRISCV32
+	default:
+		return PlatformUnknown, ProcessorUnknown, fmt.Errorf("unsupported processor in target triple: %q", triple)
+	}
+
+	platform := PlatformUnknown
+	for _, part := range parts[1:] {
+		switch {
+		case part == "linux":
+			platform = PlatformLinux
+		case strings.HasPrefix(part, "darwin") || strings.HasPrefix(part, "macos"):
+			platform = PlatformMac
+		case strings.HasPrefix(part, "freebsd"):
+			platform = PlatformFreeBSD
+		case part == "windows" || strings.HasPrefix(part, "mingw") || part == "cygwin" || part == "msvc":
+			platform = PlatformWindows
+		}
+	}
+	if platform == PlatformUnknown {
+		return PlatformUnknown, ProcessorUnknown, fmt.Errorf("unsupported system in target triple: %q", triple)
+	}
+
+	return platform, processor, nil
+}